@@ -4,12 +4,14 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/arbiosu/edgar/types"
 )
 
-func setupFlags(c *types.ClientConfig, g *types.GetConfig) map[string]*flag.FlagSet {
+func setupFlags(c *types.ClientConfig, g *types.GetConfig, x *types.IndexConfig, w *types.WatchConfig, p *types.ParseConfig, xb *types.XBRLConfig, st *types.StatementsConfig, mp *types.MappingConfig, ra *types.RatiosConfig, sc *types.ScoresConfig, cm *types.CompareConfig, rs *types.RestatementsConfig, fa *types.FactsConfig, pq *types.ParquetConfig) map[string]*flag.FlagSet {
 
 	var (
 		sh     = "(shorthand)"
@@ -17,14 +19,83 @@ func setupFlags(c *types.ClientConfig, g *types.GetConfig) map[string]*flag.Flag
 		year   = now.Year()
 		client = flag.NewFlagSet("client", flag.ExitOnError)
 		get    = flag.NewFlagSet("get", flag.ExitOnError)
+		index  = flag.NewFlagSet("index", flag.ExitOnError)
+		watch  = flag.NewFlagSet("watch", flag.ExitOnError)
+		parse  = flag.NewFlagSet("parse", flag.ExitOnError)
+		xbrl   = flag.NewFlagSet("xbrl", flag.ExitOnError)
+		stmts  = flag.NewFlagSet("statements", flag.ExitOnError)
+		mapset = flag.NewFlagSet("mapping", flag.ExitOnError)
+		ratios = flag.NewFlagSet("ratios", flag.ExitOnError)
+		scores = flag.NewFlagSet("scores", flag.ExitOnError)
+		cmpset = flag.NewFlagSet("compare", flag.ExitOnError)
+		restat = flag.NewFlagSet("restatements", flag.ExitOnError)
+		factfs = flag.NewFlagSet("facts", flag.ExitOnError)
+		pqset  = flag.NewFlagSet("parquet", flag.ExitOnError)
 		email  = "Your email address"
 		usage  = "Usage statement"
 		cik    = "CIK number"
 		ticker = "Stock ticker"
-		doc    = "Desired document (10-K, 10-Q)"
+		doc    = "Desired form or group (10-K, 10-Q, 20-F, annual, quarterly)"
 		period = "Time period"
+		years  = "Range of fiscal years, e.g. 2019-2024"
+		qtrs   = "Number of latest quarters to report"
+		sched  = "Supplemental schedules (oci,metrics,sbc,taxes,leases,debt,intangibles,commitments,derivatives,equity,other; all or none)"
 		save   = "Name of the file to be saved"
-		format = "Download raw HTML files or get a JSON report"
+		format = "Download raw HTML files or get a JSON, CSV, TSV or XLSX report"
+		store  = "Path to a local SQLite fact store to read from"
+		sync   = "Refresh the fact store from the SEC API"
+		date   = "Daily index date (YYYY-MM-DD)"
+		qtr    = "Full index quarter (YYYYQn)"
+		kind   = "Index file type (form, master, company)"
+		forms  = "Comma separated form types or groups to keep (8-K,10-Q,insider); add /A for amendments"
+		file   = "Parse a local index file (.idx, .gz or .zip)"
+		jsonl  = "Print entries as JSON lines"
+		dl     = "Download the listed filings to app/index/"
+		list   = "Comma separated tickers to watch"
+		every  = "Time between polls"
+		state  = "File remembering filings already seen"
+		hook   = "Webhook URL that receives each new filing as JSON"
+		cmd    = "Shell command run for each new filing (event JSON on stdin)"
+		back   = "Emit existing filings the first time a company is watched"
+		once   = "Poll once and exit"
+		wait   = "Time allowed for each webhook or command hook to finish"
+		output = "Output format (text, markdown; json, csv with -tables)"
+		tables = "Extract financial tables"
+		inline = "Extract inline XBRL facts as JSON"
+		accn   = "Accession number of the filing"
+		dir    = "Local directory holding the filing's XBRL package"
+		axis   = "Dimension axis, e.g. us-gaap:StatementBusinessSegmentsAxis"
+		member = "Axis member to list the facts of"
+		end    = "Period end date of the segment table (latest if empty)"
+		tabfmt = "Output format (json, csv)"
+		rdir   = "Local directory holding FilingSummary.xml and the R pages"
+		items  = "Comma separated items to extract as JSON (1A,7, II-1A or all)"
+		valid  = "Check the report's accounting identities, exit non-zero on breaks"
+		mapf   = "XBRL mapping overrides layered over the default (JSON)"
+		rfmt   = "Output format (text, json, csv)"
+		wlist  = "File of tickers to score, one per line"
+		fyear  = "Fiscal year to score (latest if 0)"
+		sfmt   = "Output format (text, json)"
+		mets   = "Comma separated metrics (revenue,netIncome,grossMargin)"
+		scale  = "Display USD and share amounts of an XLSX report in units, thousands or millions"
+		layout = "Layout of a CSV or TSV report: flat (a row per value) or pivot (a column per period)"
+		amend  = "Also download amended filings, such as 10-K/A (html format)"
+		cons   = "Comma separated XBRL concepts, all if empty"
+		allp   = "List every reported period, not only restated ones"
+		hfmt   = "Output format (text, json)"
+		fforms = "Comma separated forms or groups to keep (10-K,annual), all if empty"
+		flist  = "List the concepts reported instead of their facts"
+		ffmt   = "Output format (csv, tsv, json)"
+		pqdir  = "Directory to write the Parquet dataset to"
+		part   = "Partition facts by taxonomy or fy (fiscal year of the period)"
+		pqall  = "Export every company in the store"
+		pqfil  = "Also export filing metadata from the submissions"
+		schema = "Print the Parquet schemas and exit"
+		pqlist = "File of tickers to export, one per line"
+		calq   = "Calendar year or quarter, e.g. CY2023Q2, aligned to each company's fiscal calendar"
+		cal    = "Calendar year or quarter to compare (2023, 2023Q2)"
+		cfmt   = "Output format (table, csv, json)"
+		taxo   = "us-gaap taxonomy schema (.xsd) or concept list to validate against (required)"
 	)
 
 	client.StringVar(&c.Email, "email", "hello@example.com", email)
@@ -39,25 +110,188 @@ func setupFlags(c *types.ClientConfig, g *types.GetConfig) map[string]*flag.Flag
 	get.StringVar(&g.Doc, "d", "10-K", doc+sh)
 	get.IntVar(&g.Period, "period", year, period)
 	get.IntVar(&g.Period, "p", year, period+sh)
+	get.StringVar(&g.Years, "years", "", years)
+	get.IntVar(&g.Quarters, "quarters", 0, qtrs)
+	get.StringVar(&g.Calendar, "calendar", "", calq)
+	get.StringVar(&g.Sections, "sections", "all", sched)
 	get.StringVar(&g.RawFile, "save", "", save)
 	get.StringVar(&g.RawFile, "s", "", save+sh)
 	get.StringVar(&g.Format, "format", "html", format)
 	get.StringVar(&g.Format, "f", "html", format+sh)
+	get.StringVar(&g.Store, "store", "", store)
+	get.BoolVar(&g.Sync, "sync", false, sync)
+	get.StringVar(&g.Mapping, "mapping", "", mapf)
+	get.BoolVar(&g.Validate, "validate", false, valid)
+	get.BoolVar(&g.Amended, "include-amendments", false, amend)
+	get.StringVar(&g.Scale, "scale", "millions", scale)
+	get.StringVar(&g.Layout, "layout", "flat", layout)
+
+	index.StringVar(&x.Date, "date", "", date)
+	index.StringVar(&x.Quarter, "quarter", "", qtr)
+	index.StringVar(&x.Quarter, "q", "", qtr+sh)
+	index.StringVar(&x.Kind, "kind", "master", kind)
+	index.StringVar(&x.Form, "form", "", forms)
+	index.StringVar(&x.File, "file", "", file)
+	index.BoolVar(&x.JSON, "json", false, jsonl)
+	index.BoolVar(&x.Download, "download", false, dl)
+
+	watch.StringVar(&w.Tickers, "tickers", "", list)
+	watch.StringVar(&w.Tickers, "t", "", list+sh)
+	watch.StringVar(&w.Forms, "form", "8-K,10-Q,10-K", forms)
+	watch.DurationVar(&w.Interval, "interval", 5*time.Minute, every)
+	watch.StringVar(&w.State, "state", "config/watch_state.json", state)
+	watch.StringVar(&w.Webhook, "webhook", "", hook)
+	watch.StringVar(&w.Exec, "exec", "", cmd)
+	watch.BoolVar(&w.Backfill, "backfill", false, back)
+	watch.BoolVar(&w.Once, "once", false, once)
+	watch.DurationVar(&w.Timeout, "hook-timeout", 30*time.Second, wait)
+
+	parse.StringVar(&p.Format, "format", "text", output)
+	parse.StringVar(&p.Format, "f", "text", output+sh)
+	parse.StringVar(&p.Sections, "sections", "", items)
+	parse.BoolVar(&p.Tables, "tables", false, tables)
+	parse.BoolVar(&p.Inline, "ixbrl", false, inline)
+
+	xbrl.StringVar(&xb.Ticker, "ticker", "", ticker)
+	xbrl.StringVar(&xb.Ticker, "t", "", ticker+sh)
+	xbrl.StringVar(&xb.CIK, "cik", "", cik)
+	xbrl.StringVar(&xb.Accession, "accession", "", accn)
+	xbrl.StringVar(&xb.Dir, "dir", "", dir)
+	xbrl.StringVar(&xb.Axis, "axis", "", axis)
+	xbrl.StringVar(&xb.Member, "member", "", member)
+	xbrl.StringVar(&xb.End, "end", "", end)
+	xbrl.StringVar(&xb.Format, "format", "json", tabfmt)
+	xbrl.StringVar(&xb.Format, "f", "json", tabfmt+sh)
+
+	stmts.StringVar(&st.Ticker, "ticker", "", ticker)
+	stmts.StringVar(&st.Ticker, "t", "", ticker+sh)
+	stmts.StringVar(&st.CIK, "cik", "", cik)
+	stmts.StringVar(&st.Accession, "accession", "", accn)
+	stmts.StringVar(&st.Dir, "dir", "", rdir)
+	stmts.StringVar(&st.Format, "format", "json", tabfmt)
+	stmts.StringVar(&st.Format, "f", "json", tabfmt+sh)
+
+	mapset.StringVar(&mp.Taxonomy, "taxonomy", "", taxo)
+
+	ratios.StringVar(&ra.CIK, "cik", "", cik)
+	ratios.StringVar(&ra.Doc, "doc", "10-K", doc)
+	ratios.StringVar(&ra.Doc, "d", "10-K", doc+sh)
+	ratios.IntVar(&ra.Period, "period", year, period)
+	ratios.IntVar(&ra.Period, "p", year, period+sh)
+	ratios.StringVar(&ra.Years, "years", "", years)
+	ratios.IntVar(&ra.Quarters, "quarters", 0, qtrs)
+	ratios.StringVar(&ra.Calendar, "calendar", "", calq)
+	ratios.StringVar(&ra.Store, "store", "", store)
+	ratios.BoolVar(&ra.Sync, "sync", false, sync)
+	ratios.StringVar(&ra.Mapping, "mapping", "", mapf)
+	ratios.StringVar(&ra.Output, "format", "text", rfmt)
+	ratios.StringVar(&ra.Output, "f", "text", rfmt+sh)
+
+	scores.StringVar(&sc.List, "tickers", "", list)
+	scores.StringVar(&sc.List, "t", "", list+sh)
+	scores.StringVar(&sc.Watchlist, "watchlist", "", wlist)
+	scores.StringVar(&sc.CIK, "cik", "", cik)
+	scores.IntVar(&sc.Year, "year", 0, fyear)
+	scores.StringVar(&sc.Store, "store", "", store)
+	scores.BoolVar(&sc.Sync, "sync", false, sync)
+	scores.StringVar(&sc.Mapping, "mapping", "", mapf)
+	scores.StringVar(&sc.Output, "format", "text", sfmt)
+	scores.StringVar(&sc.Output, "f", "text", sfmt+sh)
+
+	cmpset.StringVar(&cm.Metrics, "metrics", "revenue,netIncome,grossMargin", mets)
+	cmpset.StringVar(&cm.Calendar, "period", strconv.Itoa(year-1), cal)
+	cmpset.StringVar(&cm.Calendar, "p", strconv.Itoa(year-1), cal+sh)
+	cmpset.StringVar(&cm.Calendar, "calendar", strconv.Itoa(year-1), cal)
+	cmpset.StringVar(&cm.Store, "store", "", store)
+	cmpset.BoolVar(&cm.Sync, "sync", false, sync)
+	cmpset.StringVar(&cm.Mapping, "mapping", "", mapf)
+	cmpset.StringVar(&cm.Output, "format", "table", cfmt)
+	cmpset.StringVar(&cm.Output, "f", "table", cfmt+sh)
+
+	restat.StringVar(&rs.CIK, "cik", "", cik)
+	restat.StringVar(&rs.Concepts, "concepts", "", cons)
+	restat.BoolVar(&rs.All, "all", false, allp)
+	restat.StringVar(&rs.Store, "store", "", store)
+	restat.BoolVar(&rs.Sync, "sync", false, sync)
+	restat.StringVar(&rs.Output, "format", "text", hfmt)
+	restat.StringVar(&rs.Output, "f", "text", hfmt+sh)
+
+	factfs.StringVar(&fa.CIK, "cik", "", cik)
+	factfs.StringVar(&fa.Concepts, "concepts", "", cons)
+	factfs.StringVar(&fa.Forms, "form", "", fforms)
+	factfs.BoolVar(&fa.List, "list", false, flist)
+	factfs.StringVar(&fa.Store, "store", "", store)
+	factfs.BoolVar(&fa.Sync, "sync", false, sync)
+	factfs.StringVar(&fa.Output, "format", "csv", ffmt)
+	factfs.StringVar(&fa.Output, "f", "csv", ffmt+sh)
+
+	pqset.StringVar(&pq.CIK, "cik", "", cik)
+	pqset.StringVar(&pq.Watchlist, "watchlist", "", pqlist)
+	pqset.BoolVar(&pq.All, "all", false, pqall)
+	pqset.StringVar(&pq.Dir, "dir", "app/parquet", pqdir)
+	pqset.StringVar(&pq.Partition, "partition", "taxonomy", part)
+	pqset.BoolVar(&pq.Filings, "filings", false, pqfil)
+	pqset.BoolVar(&pq.Schema, "schema", false, schema)
+	pqset.StringVar(&pq.Store, "store", "", store)
+	pqset.BoolVar(&pq.Sync, "sync", false, sync)
 
 	m := make(map[string]*flag.FlagSet)
 	m["client"] = client
 	m["get"] = get
+	m["index"] = index
+	m["watch"] = watch
+	m["parse"] = parse
+	m["xbrl"] = xbrl
+	m["statements"] = stmts
+	m["mapping"] = mapset
+	m["ratios"] = ratios
+	m["scores"] = scores
+	m["compare"] = cmpset
+	m["restatements"] = restat
+	m["facts"] = factfs
+	m["parquet"] = pqset
 
 	return m
 }
 
+// Splits off a leading positional argument, such as the ticker in
+// "edgar statements AAPL -accession X", so the flags after it are parsed
+func leadingArg(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// Splits off the leading positional tickers, as in "edgar scores AAPL MSFT
+// -year 2023", and returns the flags after them
+func leadingTickers(args []string, tickers *[]string) []string {
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		*tickers = append(*tickers, args[0])
+		args = args[1:]
+	}
+	return args
+}
+
 func main() {
 	c := &types.ClientConfig{}
 	g := &types.GetConfig{}
-	m := setupFlags(c, g)
+	x := &types.IndexConfig{}
+	w := &types.WatchConfig{}
+	p := &types.ParseConfig{}
+	xb := &types.XBRLConfig{}
+	st := &types.StatementsConfig{}
+	mp := &types.MappingConfig{}
+	ra := &types.RatiosConfig{}
+	sc := &types.ScoresConfig{}
+	cm := &types.CompareConfig{}
+	rs := &types.RestatementsConfig{}
+	fa := &types.FactsConfig{}
+	pq := &types.ParquetConfig{}
+	m := setupFlags(c, g, x, w, p, xb, st, mp, ra, sc, cm, rs, fa, pq)
 
 	if len(os.Args) < 2 {
-		fmt.Println("Error: expected 'client' or 'get' subcommands. Exiting...")
+		fmt.Println("Error: expected 'client', 'get', 'index', 'watch', 'parse', 'xbrl', 'statements', 'mapping', 'ratios', 'scores', 'compare', 'restatements', 'facts' or 'parquet' subcommands. Exiting...")
 		os.Exit(1)
 	}
 
@@ -69,10 +303,66 @@ func main() {
 	case "get":
 		m["get"].Parse(os.Args[2:])
 		g.HandleGet()
+	case "index":
+		m["index"].Parse(os.Args[2:])
+		x.HandleIndex()
+	case "watch":
+		m["watch"].Parse(os.Args[2:])
+		w.HandleWatch()
 	case "parse":
-		m["parse"].Parse(os.Args[2:])
+		var args []string
+		p.File, args = leadingArg(os.Args[2:])
+		m["parse"].Parse(args)
+		if p.File == "" {
+			p.File = m["parse"].Arg(0)
+		}
+		p.HandleParse()
+	case "xbrl":
+		m["xbrl"].Parse(os.Args[2:])
+		xb.HandleXBRL()
+	case "statements":
+		var args []string
+		st.Ticker, args = leadingArg(os.Args[2:])
+		m["statements"].Parse(args)
+		st.HandleStatements()
+	case "mapping":
+		var args []string
+		mp.Command, args = leadingArg(os.Args[2:])
+		mp.File, args = leadingArg(args)
+		m["mapping"].Parse(args)
+		if mp.File == "" {
+			mp.File = m["mapping"].Arg(0)
+		}
+		mp.HandleMapping()
+	case "ratios":
+		var args []string
+		ra.Ticker, args = leadingArg(os.Args[2:])
+		m["ratios"].Parse(args)
+		ra.HandleRatios()
+	case "scores":
+		m["scores"].Parse(leadingTickers(os.Args[2:], &sc.Tickers))
+		sc.Tickers = append(sc.Tickers, m["scores"].Args()...)
+		sc.HandleScores()
+	case "compare":
+		m["compare"].Parse(leadingTickers(os.Args[2:], &cm.Tickers))
+		cm.Tickers = append(cm.Tickers, m["compare"].Args()...)
+		cm.HandleCompare()
+	case "restatements":
+		var args []string
+		rs.Ticker, args = leadingArg(os.Args[2:])
+		m["restatements"].Parse(args)
+		rs.HandleRestatements()
+	case "facts":
+		var args []string
+		fa.Ticker, args = leadingArg(os.Args[2:])
+		m["facts"].Parse(args)
+		fa.HandleFacts()
+	case "parquet":
+		m["parquet"].Parse(leadingTickers(os.Args[2:], &pq.Tickers))
+		pq.Tickers = append(pq.Tickers, m["parquet"].Args()...)
+		pq.HandleParquet()
 	default:
-		fmt.Println("Expected 'client' or 'get' subcommands")
+		fmt.Println("Expected 'client', 'get', 'index', 'watch', 'parse', 'xbrl', 'statements', 'mapping', 'ratios', 'scores', 'compare', 'restatements', 'facts' or 'parquet' subcommands")
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,184 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Days a 52/53-week fiscal year may end either side of its nominal year end
+const yearEndSlack = 7
+
+// A fiscalCalendar places the periods of one company on the calendar. It is
+// anchored on the fiscal year end from the company's submissions: "0930" for
+// Apple, "0131" for Walmart.
+type fiscalCalendar struct {
+	month time.Month
+	day   int
+}
+
+// The fiscal calendar of companies reporting on the calendar year
+var calendarYear = fiscalCalendar{month: time.December, day: 31}
+
+// Parses a fiscal year end in the MMDD form used by submissions
+func parseFiscalYearEnd(mmdd string) (fiscalCalendar, error) {
+	t, err := time.Parse("0102", mmdd)
+	if err != nil {
+		return fiscalCalendar{}, fmt.Errorf("invalid fiscal year end %q", mmdd)
+	}
+	return fiscalCalendar{month: t.Month(), day: t.Day()}, nil
+}
+
+// Returns the fiscal year end in the MMDD form used by submissions
+func (fc fiscalCalendar) String() string {
+	return fmt.Sprintf("%02d%02d", int(fc.month), fc.day)
+}
+
+// Infers the fiscal year end from the most common end of the annual facts
+// reported in annual reports, defaulting to the calendar year
+func inferFiscalYearEnd(f *CompanyFacts) fiscalCalendar {
+	counts := make(map[string]int)
+	for _, fact := range f.Facts.Data {
+		for i := range fact.Units.USD {
+			v := &fact.Units.USD[i]
+			if inFormGroup(v.Form, "annual") && v.ForPeriod == "FY" && coversFiscalPeriod(v, "FY") && len(v.PeriodEnd) == 10 {
+				counts[v.PeriodEnd[5:7]+v.PeriodEnd[8:10]]++
+			}
+		}
+	}
+	ends := make([]string, 0, len(counts))
+	for end := range counts {
+		ends = append(ends, end)
+	}
+	sort.Slice(ends, func(i, j int) bool {
+		if counts[ends[i]] != counts[ends[j]] {
+			return counts[ends[i]] > counts[ends[j]]
+		}
+		return ends[i] < ends[j]
+	})
+	if len(ends) > 0 {
+		if fc, err := parseFiscalYearEnd(ends[0]); err == nil {
+			return fc
+		}
+	}
+	return calendarYear
+}
+
+// Returns the fiscal calendar of the configured company from the fiscal year
+// end in its submissions. Reads served from the store stay offline, so they,
+// and companies whose submissions cannot be read, use the year end inferred
+// from their facts.
+func (g *GetConfig) loadFiscalCalendar(c *ClientConfig, f *CompanyFacts) fiscalCalendar {
+	if g.Store == "" || g.Sync {
+		cf, err := c.getCompanyFilings(g.CIK)
+		if err == nil {
+			var fc fiscalCalendar
+			fc, err = parseFiscalYearEnd(cf.FiscalYearEnd)
+			if err == nil {
+				return fc
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Could not read the fiscal year end of %s, inferring it from its facts (%v)\n", g.CIK, err)
+	}
+	return inferFiscalYearEnd(f)
+}
+
+// Returns the end of the fiscal year a period ending on end belongs to
+func (fc fiscalCalendar) yearEnd(end time.Time) time.Time {
+	fye := time.Date(end.Year(), fc.month, fc.day, 0, 0, 0, 0, time.UTC)
+	if end.After(fye.AddDate(0, 0, yearEndSlack)) {
+		fye = fye.AddDate(1, 0, 0)
+	}
+	return fye
+}
+
+// Returns the fiscal year and quarter of a period ending on end. Fiscal
+// years are named after the calendar year they end in.
+func (fc fiscalCalendar) fiscalPeriod(end time.Time) (int, int) {
+	fye := fc.yearEnd(end)
+	left := int(math.Round(fye.Sub(end).Hours() / 24 / 91.3))
+	return fye.Year(), max(1, 4-left)
+}
+
+// Returns the fiscal year and period ("FY" or "Q1"-"Q4") aligned to the
+// calendar year, or to the calendar quarter if quarter is not 0: the one
+// whose middle falls within it, as calendarPeriod does for report columns
+func (fc fiscalCalendar) fiscalFor(year, quarter int) (int, string) {
+	if quarter == 0 {
+		fye := fc.yearEnd(time.Date(year, time.July, 1, 0, 0, 0, 0, time.UTC))
+		return fye.Year(), "FY"
+	}
+	mid := time.Date(year, time.Month(3*quarter-1), 15, 0, 0, 0, 0, time.UTC)
+	fye := fc.yearEnd(mid)
+	end := fye
+	for k := 1; k < 4; k++ {
+		if e := fye.AddDate(0, -3*k, 0); !e.Before(mid) {
+			end = e
+		}
+	}
+	fy, q := fc.fiscalPeriod(end)
+	return fy, fmt.Sprintf("Q%d", q)
+}
+
+// A FactPeriod places a fact on the company's fiscal calendar and the
+// calendar. A fact's own fy and fp are those of the filing that reported it,
+// which differ for the comparative periods every filing repeats.
+type FactPeriod struct {
+	FiscalYear   int    `json:"fy"`
+	FiscalPeriod string `json:"fp"`       // FY, Q1-Q4, or the months of a year-to-date duration, e.g. 9M
+	Calendar     string `json:"calendar"` // e.g. CY2023, CY2023Q2, or CY2023Q2I for instants; empty for year-to-date durations
+}
+
+// Returns the fiscal and calendar periods of a fact from its period dates
+func (fc fiscalCalendar) align(e *UnitEntry) FactPeriod {
+	end, err := time.Parse("2006-01-02", e.PeriodEnd)
+	if err != nil {
+		return FactPeriod{}
+	}
+	fy, q := fc.fiscalPeriod(end)
+	p := FactPeriod{FiscalYear: fy, FiscalPeriod: fmt.Sprintf("Q%d", q)}
+	days := durationDays(e)
+	switch {
+	case e.PeriodStart == "":
+		p.Calendar = calendarPeriod(e.PeriodEnd, false) + "I"
+	case days >= minYearDays && days <= maxYearDays:
+		p.FiscalPeriod = "FY"
+		p.Calendar = calendarPeriod(e.PeriodEnd, true)
+	case days >= minQuarterDays && days <= maxQuarterDays:
+		p.Calendar = calendarPeriod(e.PeriodEnd, false)
+	default:
+		p.FiscalPeriod = fmt.Sprintf("%dM", int(math.Round(float64(days)/30.4)))
+	}
+	return p
+}
+
+// Parses a calendar year or quarter: 2023, CY2023, 2023Q2 or CY2023Q2.
+// The quarter is 0 for a calendar year.
+func parseCalendar(s string) (int, int, error) {
+	t := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "CY")
+	year, quarter, found := strings.Cut(t, "Q")
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid calendar period %q, expected e.g. CY2023 or CY2023Q2", s)
+	}
+	if !found {
+		return y, 0, nil
+	}
+	q, err := strconv.Atoi(quarter)
+	if err != nil || q < 1 || q > 4 {
+		return 0, 0, fmt.Errorf("invalid quarter in %q", s)
+	}
+	return y, q, nil
+}
+
+// Formats a calendar year or quarter the way Period.Calendar does
+func calendarLabel(year, quarter int) string {
+	if quarter == 0 {
+		return fmt.Sprintf("CY%d", year)
+	}
+	return fmt.Sprintf("CY%dQ%d", year, quarter)
+}
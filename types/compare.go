@@ -0,0 +1,217 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// Short names accepted by -metrics for standardized lines and ratios
+var metricAliases = map[string]string{
+	"revenue":  "TotalRevenue",
+	"sales":    "TotalRevenue",
+	"cogs":     "COGS",
+	"sga":      "SG&A",
+	"rd":       "R&D",
+	"ppe":      "PP&E",
+	"eps":      "EPS (Diluted)",
+	"fcf":      "Free Cash Flow",
+	"assets":   "TotalAssets",
+	"equity":   "TotalEquity",
+	"capex":    "CapitalExpenditures",
+	"ocf":      "OperatingCashFlow",
+	"shares":   "DilutedShares",
+	"dividend": "DividendsPerShare",
+}
+
+// Normalizes a metric name for matching: "Gross Margin", "grossMargin" and
+// "gross_margin" are the same metric
+func metricKey(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// Resolves a -metrics name to the name of a standardized line or ratio
+func resolveMetric(name string) (string, bool) {
+	key := metricKey(name)
+	if alias, ok := metricAliases[key]; ok {
+		key = metricKey(alias)
+	}
+	for _, sc := range standardConcepts {
+		if metricKey(sc.Name) == key {
+			return sc.Name, true
+		}
+	}
+	for _, def := range ratioDefs {
+		if metricKey(def.name) == key {
+			return def.name, true
+		}
+	}
+	return "", false
+}
+
+// Returns the names every -metrics name can resolve to
+func metricNames() []string {
+	names := make([]string, 0, len(standardConcepts)+len(ratioDefs))
+	for _, sc := range standardConcepts {
+		names = append(names, sc.Name)
+	}
+	for _, def := range ratioDefs {
+		names = append(names, def.name)
+	}
+	return names
+}
+
+// Returns the value of a standardized line or ratio in a column of the report
+func (r *FinancialStatement) metric(name string, col int) *float64 {
+	if v, ok := r.standardNumber(name, col); ok {
+		return &v
+	}
+	for _, ratio := range r.Ratios {
+		if ratio.Name == name {
+			return ratio.Values[col].Value
+		}
+	}
+	return nil
+}
+
+// One company's column of a comparison
+type Comparison struct {
+	Ticker        string              `json:"ticker"`
+	Entity        string              `json:"entity,omitempty"`
+	FiscalYearEnd string              `json:"fiscalYearEnd,omitempty"` // MMDD
+	Period        *Period             `json:"period"`                  // the fiscal period aligned to the requested calendar period
+	Metrics       map[string]*float64 `json:"metrics"`
+	Error         string              `json:"error,omitempty"`
+}
+
+// Holds the arguments of the compare subcommand
+type CompareConfig struct {
+	GetConfig
+	Tickers []string
+	Metrics string // comma separated metrics
+	Output  string // table, csv or json
+}
+
+func (cc *CompareConfig) HandleCompare() {
+	if len(cc.Tickers) < 1 {
+		fmt.Println("Error: expected tickers, e.g. edgar compare AAPL MSFT -metrics revenue,netIncome. Exiting...")
+		os.Exit(1)
+	}
+	year, quarter, err := parseCalendar(cc.Calendar)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	calendar := calendarLabel(year, quarter)
+	metrics := make([]string, 0)
+	for _, m := range strings.Split(cc.Metrics, ",") {
+		name, ok := resolveMetric(m)
+		if !ok {
+			fmt.Printf("Error: unknown metric %q! Expected one of: %s\n", m, strings.Join(metricNames(), ", "))
+			os.Exit(1)
+		}
+		metrics = append(metrics, name)
+	}
+
+	c := checkConfig()
+	xbrl := getXBRLTags(cc.Mapping)
+	tickers := c.checkCompanyTickers()
+	comparisons := make([]Comparison, 0, len(cc.Tickers))
+	for _, t := range cc.Tickers {
+		t = strings.ToUpper(t)
+		cmp := Comparison{Ticker: t, Metrics: make(map[string]*float64)}
+		cik, ok := tickers[t]
+		if !ok {
+			cmp.Error = "ticker not found"
+			comparisons = append(comparisons, cmp)
+			continue
+		}
+		g := cc.GetConfig
+		g.Ticker, g.CIK = t, zeroPad(strconv.Itoa(cik))
+		facts, err := g.companyFacts(c, assembleUrl(g.CIK, companyFacts))
+		if err != nil {
+			cmp.Error = err.Error()
+			comparisons = append(comparisons, cmp)
+			continue
+		}
+		cmp.Entity = facts.EntityName
+		g.Calendar, g.Sections = calendar, "none"
+		g.fiscal = g.loadFiscalCalendar(c, facts)
+		cmp.FiscalYearEnd = g.fiscal.String()
+		r, err := g.assembleReport(facts, xbrl)
+		if err != nil {
+			cmp.Error = err.Error()
+			comparisons = append(comparisons, cmp)
+			continue
+		}
+		col := len(r.Periods) - 1
+		cmp.Period = &r.Periods[col]
+		for _, m := range metrics {
+			cmp.Metrics[m] = r.metric(m, col)
+		}
+		comparisons = append(comparisons, cmp)
+	}
+
+	switch cc.Output {
+	case "json":
+		b, err := json.MarshalIndent(comparisons, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal comparison! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		err := w.WriteAll(comparisonRows(calendar, metrics, comparisons, "%g"))
+		if err != nil {
+			fmt.Printf("Error: could not write comparison! (%v)\n", err)
+			os.Exit(1)
+		}
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, row := range comparisonRows(calendar, metrics, comparisons, "%.4g") {
+			fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+		}
+		w.Flush()
+	}
+}
+
+// Lays out a comparison as rows of metrics with a column per company, headed
+// by the ticker and the fiscal period aligned to the calendar period
+func comparisonRows(calendar string, metrics []string, comparisons []Comparison, format string) [][]string {
+	header := []string{calendar}
+	fiscal := []string{"fiscal period"}
+	for _, cmp := range comparisons {
+		header = append(header, cmp.Ticker)
+		switch {
+		case cmp.Error != "":
+			fiscal = append(fiscal, cmp.Error)
+		default:
+			fiscal = append(fiscal, cmp.Period.Label+" ("+cmp.Period.End+")")
+		}
+	}
+	rows := [][]string{header, fiscal}
+	for _, m := range metrics {
+		row := []string{m}
+		for _, cmp := range comparisons {
+			if v := cmp.Metrics[m]; v != nil {
+				row = append(row, fmt.Sprintf(format, *v))
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
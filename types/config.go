@@ -6,9 +6,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -45,7 +47,8 @@ func (c *ClientConfig) HandleClient() {
 // Check for a previous client configuration. If the config.json file does not
 // exist. TODO: create one
 func checkConfig() *ClientConfig {
-	fmt.Println("Checking for previous client configuration...")
+	// Progress goes to stderr so commands that stream results on stdout stay parseable
+	fmt.Fprintln(os.Stderr, "Checking for previous client configuration...")
 	c, err := os.ReadFile("./config/config.json")
 	if err != nil {
 		fmt.Printf("Error: could not read config file: (%v)\n", err)
@@ -58,14 +61,14 @@ func checkConfig() *ClientConfig {
 		fmt.Printf("Error reading config file: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("Config found!")
+	fmt.Fprintln(os.Stderr, "Config found!")
 	return &clientConfig
 }
 
 // Downloads company_tickers.json to config/ directory. Returns an error if
 // unsuccessful
 func (c *ClientConfig) getCompanyTickers() error {
-	b, err := c.makeSecRequest("")
+	b, err := c.makeSecRequest(companyTickers)
 	if err != nil {
 		return err
 	}
@@ -92,9 +95,7 @@ func (c *ClientConfig) checkCompanyTickers() map[string]int {
 		} else {
 			fmt.Println("Success! File company_tickers.json downloaded!")
 			// Recursive call to unmarshal JSON
-			// TODO: Bug here. File downloads then runs into unexpected end of
-			// JSON input. May change to return here and instruct to try again
-			c.checkCompanyTickers()
+			return c.checkCompanyTickers()
 		}
 	}
 	// TODO: rethink this section, figure out how we want to save the file
@@ -120,22 +121,31 @@ func (c *ClientConfig) checkCompanyTickers() map[string]int {
 
 // Returns the CompanyFacts struct for a given ticker/CIK
 func (c *ClientConfig) getCompanyFacts(url string) *CompanyFacts {
-	body, err := c.makeSecRequest(url)
+	cf, err := c.fetchCompanyFacts(url)
 	if err != nil {
-		fmt.Printf("Error: could not make SEC Request! (%v)\n", err)
+		fmt.Printf("Error: %v!\n", err)
 		os.Exit(1)
 	}
+	return cf
+}
+
+// Requests the company facts at url
+func (c *ClientConfig) fetchCompanyFacts(url string) (*CompanyFacts, error) {
+	body, err := c.makeSecRequest(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not make SEC Request (%v)", err)
+	}
 	var cf CompanyFacts
 	err = json.Unmarshal(body, &cf)
 	if err != nil {
-		fmt.Printf("Error: could not unmarshal JSON! (%V)\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not unmarshal JSON (%v)", err)
 	}
-	return &cf
+	return &cf, nil
 }
 
 // Makes a GET request to the given URL and returns the response body.
 func (c *ClientConfig) makeSecRequest(url string) ([]byte, error) {
+	secLimiter.wait()
 	client := http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -150,6 +160,9 @@ func (c *ClientConfig) makeSecRequest(url string) ([]byte, error) {
 		return nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
@@ -158,59 +171,134 @@ func (c *ClientConfig) makeSecRequest(url string) ([]byte, error) {
 }
 
 type GetConfig struct {
-	CIK     string
-	Ticker  string
-	Doc     string
-	Period  int
-	RawFile string
-	Format  string // JSON or HTML
+	CIK      string
+	Ticker   string
+	Doc      string
+	Period   int
+	Years    string // range of fiscal years, e.g. 2019-2024, overrides Period
+	Quarters int    // number of latest quarters, overrides Period and Years
+	Calendar string // calendar year or quarter, e.g. CY2023Q2, overrides Period, Years and Quarters
+	Sections string // supplemental schedules to assemble, see assembleSupplemental
+	Mapping  string // XBRL mapping overrides, layered over the default
+	Validate bool   // check the accounting identities of the report
+	Amended  bool   // also download amended filings, such as 10-K/A
+	RawFile  string
+	Format   string // JSON, CSV, TSV, XLSX or HTML
+	Scale    string // units, thousands or millions, for XLSX
+	Layout   string // flat or pivot, for CSV and TSV
+	Store    string // path to a local SQLite fact store, optional
+	Sync     bool   // refresh the store from the SEC API before reading
+
+	periods []Period       // columns of the report being assembled
+	fiscal  fiscalCalendar // set for -calendar, inferred from the facts if not
 }
 
 func (g *GetConfig) HandleGet() {
-	c := checkConfig()
-	if g.CIK == "" {
-		tickers := c.checkCompanyTickers()
-		cik, ok := tickers[g.Ticker]
-		if !ok {
-			fmt.Printf("Error: ticker not found! Exiting program.\n")
-			os.Exit(1)
-		}
-		cikStr := strconv.Itoa(cik)
-		padded := zeroPad(cikStr)
-		g.CIK = padded
+	_, err := formSet(g.Doc)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
+	if g.Layout != "flat" && g.Layout != "pivot" {
+		fmt.Printf("Error: unknown layout %q, expected flat or pivot!\n", g.Layout)
+		os.Exit(1)
+	}
+	if _, ok := scaleFormats[g.Scale]; !ok {
+		fmt.Printf("Error: unknown scale %q, expected units, thousands or millions!\n", g.Scale)
+		os.Exit(1)
+	}
+	c := checkConfig()
+	g.resolveCIK(c)
 	var url string
 	switch g.Format {
-	case "json":
+	case "json", "csv", "tsv", "xlsx":
 		url = assembleUrl(g.CIK, companyFacts)
-		facts := c.getCompanyFacts(url)
-		xbrl := getXBRLTags()
-		r, err := g.assembleReport(facts, xbrl)
-		if err != nil {
-			fmt.Printf("Error: could not assemble company report! (%v)\n", err)
-			os.Exit(1)
+		r := g.buildReport(c)
+		var err error
+		if g.Validate {
+			r.Discrepancies = r.Validate()
 		}
 		if g.RawFile == "" {
 			g.RawFile = g.Ticker + "_company_facts"
 		}
-		err = g.downloadJSON(r)
+		switch g.Format {
+		case "csv", "tsv":
+			err = g.downloadCSV(r)
+		case "xlsx":
+			err = g.downloadXLSX(r)
+		default:
+			err = g.downloadJSON(r)
+		}
 		if err != nil {
 			fmt.Printf("Error: could not download company report! (%v)\n", err)
+			os.Exit(1)
+		}
+		if len(r.Discrepancies) > 0 {
+			for _, d := range r.Discrepancies {
+				fmt.Println(d)
+			}
+			fmt.Printf("Error: report does not tie out! (%d discrepancies)\n", len(r.Discrepancies))
+			os.Exit(1)
 		}
 	case "html":
 		url = assembleUrl(g.CIK, companyFilings)
 		urls := g.getFileUrls(url, c)
-		err := g.downloadFiles(urls, c)
+		err := downloadFiles(urls, "app/"+g.Ticker+"/", c)
 		if err != nil {
 			fmt.Printf("Error: Failed to download files! (%v)\n", err)
 			os.Exit(1)
 		}
 	}
-	fmt.Printf("URL: %s\nCIK: %s\nTicker: %s\nFormat: %s\nFiling(s): %s\nPeriod: %d\n", url, g.CIK, g.Ticker, g.Format, g.Doc, g.Period)
+	fmt.Printf("URL: %s\nCIK: %s\nTicker: %s\nFormat: %s\nFiling(s): %s\nPeriod: %s\n", url, g.CIK, g.Ticker, g.Format, g.Doc, g.periodLabel())
 	fmt.Println("Your desired report(s) are located in the app/ directory. Thanks for using edgar!")
 	os.Exit(0)
 }
 
+// Looks up the CIK of g.Ticker unless a CIK was given
+func (g *GetConfig) resolveCIK(c *ClientConfig) {
+	if g.CIK != "" {
+		return
+	}
+	tickers := c.checkCompanyTickers()
+	cik, ok := tickers[g.Ticker]
+	if !ok {
+		fmt.Printf("Error: ticker not found! Exiting program.\n")
+		os.Exit(1)
+	}
+	cikStr := strconv.Itoa(cik)
+	padded := zeroPad(cikStr)
+	g.CIK = padded
+}
+
+// Loads the company facts and assembles the report
+func (g *GetConfig) buildReport(c *ClientConfig) *FinancialStatement {
+	facts := g.loadCompanyFacts(c, assembleUrl(g.CIK, companyFacts))
+	if g.Calendar != "" {
+		g.fiscal = g.loadFiscalCalendar(c, facts)
+	}
+	xbrl := getXBRLTags(g.Mapping)
+	r, err := g.assembleReport(facts, xbrl)
+	if err != nil {
+		fmt.Printf("Error: could not assemble company report! (%v)\n", err)
+		os.Exit(1)
+	}
+	return r
+}
+
+// Requests the submissions of a company: its filings and fiscal year end
+func (c *ClientConfig) getCompanyFilings(cik string) (*CompanyFilings, error) {
+	body, err := c.makeSecRequest(assembleUrl(cik, companyFilings))
+	if err != nil {
+		return nil, err
+	}
+	var cf CompanyFilings
+	err = json.Unmarshal(body, &cf)
+	if err != nil {
+		return nil, err
+	}
+	return &cf, nil
+}
+
 // Gets the URLs of the desired filings
 func (g *GetConfig) getFileUrls(url string, c *ClientConfig) []string {
 	body, err := c.makeSecRequest(url)
@@ -219,36 +307,37 @@ func (g *GetConfig) getFileUrls(url string, c *ClientConfig) []string {
 		// TODO: handle
 	}
 	var cf CompanyFilings
-	err = json.Unmarshal(body, &c)
+	err = json.Unmarshal(body, &cf)
 	if err != nil {
-		fmt.Printf("Error: could not unmarshal JSON! (%V)\n", err)
+		fmt.Printf("Error: could not unmarshal JSON! (%v)\n", err)
 		// TODO: handle
 	}
 	// Iterate over the Form slice to find the index of the desired filings.
 	// Get the accession number and the primary document at the asscoiated index.
 	// Assemble the URLs to retrieve the desired filings.
-	newUrl := "https://www.sec.gov/Archives/edgar/data/"
+	forms, err := formSet(g.Doc)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	urls := make([]string, 0)
 	for i, v := range cf.Filings.Recent.Form {
 		// TODO: Validate g.Period with c.Filings.Recent.FilingDate[i]
-		// Ensure g.Doc is correct ie: 10-K, 10-Q
-		if v == g.Doc {
-			// strip '-' from accession number
-			re := regexp.MustCompile(`-`)
-			cleaned := re.ReplaceAllString(cf.Filings.Recent.AccessionNumber[i], "")
-			urls = append(urls, newUrl+cf.Cik+"/"+cleaned+"/"+cf.Filings.Recent.PrimaryDocument[i])
+		// Amendments, such as 10-K/A, only with -include-amendments
+		if formSelected(forms, v, g.Amended) {
+			urls = append(urls, filingUrl(cf.Cik, cf.Filings.Recent.AccessionNumber[i], cf.Filings.Recent.PrimaryDocument[i]))
 		}
 	}
 	return urls
 }
 
 // TODO: rethink downloadFiles and downloadJSON
-func (g *GetConfig) downloadFiles(urls []string, c *ClientConfig) error {
-	err := createDir("app/" + g.Ticker + "/")
+// Downloads each URL into dir, named after the last element of the URL
+func downloadFiles(urls []string, dir string, c *ClientConfig) error {
+	err := createDir(dir)
 	if err != nil {
-		fmt.Printf("Error: could not create 'app' directory! (%v)\n", err)
-		os.Exit(1)
-		// TODO: handle
+		fmt.Printf("Error: could not create '%s' directory! (%v)\n", dir, err)
+		return err
 	}
 	for i := 0; i < len(urls); i++ {
 		body, err := c.makeSecRequest(urls[i])
@@ -256,12 +345,11 @@ func (g *GetConfig) downloadFiles(urls []string, c *ClientConfig) error {
 			fmt.Printf("Error: could not request %s! (%v)\n", urls[i], err)
 			return err
 		}
-		err = os.WriteFile("./app/"+g.Ticker+"/"+g.Doc+".html", body, 0666)
+		err = os.WriteFile(filepath.Join(dir, path.Base(urls[i])), body, 0666)
 		if err != nil {
 			fmt.Printf("Error: could not write file! (%v)\n", err)
 			return err
 		}
-		g.RawFile = "./app/" + g.Ticker + "/" + g.Doc + ".html"
 	}
 	return nil
 }
@@ -288,10 +376,31 @@ func (g *GetConfig) downloadJSON(r *FinancialStatement) error {
 // Assemble the financial statement report
 // TODO: FIX!!
 func (g *GetConfig) assembleReport(f *CompanyFacts, xbrl *XBRLTags) (*FinancialStatement, error) {
-	report := &FinancialStatement{}
+	periods, err := g.reportPeriods(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(periods) == 0 && g.Calendar != "" {
+		return nil, fmt.Errorf("no fiscal period aligned to %s", g.Calendar)
+	}
+	if len(periods) == 0 {
+		return nil, fmt.Errorf("no %s filings found for the requested period", g.Doc)
+	}
+	g.periods = periods
+	report := &FinancialStatement{
+		MappingVersion: xbrl.Version,
+		Periods:        periods,
+		Standardized:   g.standardize(f),
+		labels:         f.labels(),
+	}
 	g.assembleBalanceSheet(f, xbrl, report)
 	g.assembleIncomeStatement(f, xbrl, report)
 	g.assembleCashFlowStatement(f, xbrl, report)
+	err = g.assembleSupplemental(f, xbrl, report)
+	if err != nil {
+		return nil, err
+	}
+	report.Ratios = report.computeRatios()
 	return report, nil
 }
 
@@ -338,7 +447,7 @@ func (g *GetConfig) assembleIncomeStatement(f *CompanyFacts, xbrl *XBRLTags, r *
 	iterateTags(data, opIncomeLos, &r.IncomeStatement.OperatingIncomeLoss, g)
 	iterateTags(data, other, &r.IncomeStatement.OtherIncomeExpense, g)
 	iterateTags(data, incomeBeforeTax, &r.IncomeStatement.IncomeBeforeTax, g)
-	iterateTags(data, tax, &r.IncomeStatement.IncomeBeforeTax, g)
+	iterateTags(data, tax, &r.IncomeStatement.IncomeTax, g)
 	iterateTags(data, ni, &r.IncomeStatement.NetIncomeLoss, g)
 }
 
@@ -355,41 +464,83 @@ func (g *GetConfig) assembleCashFlowStatement(f *CompanyFacts, xbrl *XBRLTags, r
 	iterateTags(data, cash, &r.CashFlowStatement.CashAndCashEquivalents, g)
 }
 
-func iterateTags(d map[string]FactData, item []string, l *[]LineItem, g *GetConfig) {
-	for i := 0; i < len(item); i++ {
-		factData, ok := d[item[i]]
-		if ok {
-			relevant := g.findRelevantUnitEntries(&factData.Units.USD)
-			newLi := &LineItem{Tag: factData.Label, Data: *relevant}
-			*l = append(*l, *newLi)
-		}
+// A supplemental schedule of the report and the tags it is assembled from
+type supplementalSection struct {
+	name  string // as given to -sections
+	tags  []string
+	items *[]LineItem
+}
+
+func supplementalSections(xbrl *XBRLTags, r *FinancialStatement) []supplementalSection {
+	t := &xbrl.Tags
+	return []supplementalSection{
+		{"oci", t.OtherComprehensiveIncomeItems, &r.OtherComprehensiveIncome},
+		{"metrics", t.FinancialMetricsAndRatios, &r.FinancialMetricsAndRatios},
+		{"sbc", t.ShareBasedCompensation, &r.ShareBasedCompensation},
+		{"taxes", t.Taxes, &r.Taxes},
+		{"leases", t.Leases, &r.Leases},
+		{"debt", t.DebtAndBorrowings, &r.DebtAndBorrowings},
+		{"intangibles", t.IntangibleAssetsAndGoodwill, &r.IntangibleAssetsAndGoodwill},
+		{"commitments", t.CommitmentsAndContingencies, &r.CommitmentsAndContingencies},
+		{"derivatives", t.DerivativesAndHedging, &r.DerivativesAndHedging},
+		{"equity", t.StockAndEquityRelatedItems, &r.StockAndEquityRelatedItems},
+		{"other", t.OtherFinancialItems, &r.OtherFinancialItems},
 	}
 }
 
-func (g *GetConfig) findRelevantUnitEntries(entries *[]UnitEntry) *[]UnitEntry {
-	var relevantEntries []UnitEntry
-	for _, v := range *entries {
-		if v.Form == g.Doc && v.FiscalYear == g.Period {
-			relevantEntries = append(relevantEntries, v)
+// Assembles the supplemental schedules selected with -sections: a comma
+// separated list of section names, "all" or "none"
+func (g *GetConfig) assembleSupplemental(f *CompanyFacts, xbrl *XBRLTags, r *FinancialStatement) error {
+	sections := supplementalSections(xbrl, r)
+	wanted := make(map[string]bool)
+	switch strings.ToLower(g.Sections) {
+	case "", "none":
+		return nil
+	case "all":
+		for _, s := range sections {
+			wanted[s.name] = true
+		}
+	default:
+		known := make(map[string]bool)
+		names := make([]string, 0, len(sections))
+		for _, s := range sections {
+			known[s.name] = true
+			names = append(names, s.name)
+		}
+		for _, name := range strings.Split(strings.ToLower(g.Sections), ",") {
+			name = strings.TrimSpace(name)
+			if !known[name] {
+				return fmt.Errorf("unknown section %q, expected one of %s, all or none", name, strings.Join(names, ", "))
+			}
+			wanted[name] = true
 		}
 	}
-	return &relevantEntries
+	for _, s := range sections {
+		if wanted[s.name] {
+			iterateTags(f.Facts.Data, s.tags, s.items, g)
+		}
+	}
+	return nil
 }
 
-// Gets the XBRL tags associated with income statements, balance sheets and cash flow statements
-func getXBRLTags() *XBRLTags {
-	b, err := os.ReadFile("xbrl_to_fin-statement_mapping.json")
-	if err != nil {
-		fmt.Printf("Error: could not read xbrl mapping file! (%v)", err)
-		os.Exit(1)
-	}
-	var xbrl XBRLTags
-	err = json.Unmarshal(b, &xbrl)
-	if err != nil {
-		fmt.Printf("Error: could not unmarshal xbrl mapping file! (%v)", err)
-		os.Exit(1)
+func iterateTags(d map[string]FactData, item []string, l *[]LineItem, g *GetConfig) {
+	for i := 0; i < len(item); i++ {
+		factData, ok := d[item[i]]
+		if ok {
+			entries, unit := factData.Units.primary()
+			relevant := g.findRelevantUnitEntries(&entries, unit == "USD")
+			newLi := &LineItem{
+				Tag:     factData.Label,
+				Concept: item[i],
+				Unit:    unit,
+				Data:    relevant,
+			}
+			if unit == "USD" {
+				newLi.TTM = g.trailingTwelveMonths(entries)
+			}
+			*l = append(*l, *newLi)
+		}
 	}
-	return &xbrl
 }
 
 // Creates a directory
@@ -417,6 +568,14 @@ func zeroPad(cik string) string {
 	return cik
 }
 
+// Assemble the url of a document within a filing in the EDGAR archives
+func filingUrl(cik string, accession string, doc string) string {
+	// strip '-' from accession number
+	re := regexp.MustCompile(`-`)
+	cleaned := re.ReplaceAllString(accession, "")
+	return archives + "edgar/data/" + strings.TrimLeft(cik, "0") + "/" + cleaned + "/" + doc
+}
+
 // Assemble the url to get company info.
 func assembleUrl(cik string, url string) string {
 	return url + cik + ".json"
@@ -0,0 +1,186 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
+// Reports whether a fact comes from a periodic report, whose values may be
+// combined to derive others
+func periodicForm(form string) bool {
+	return inFormGroup(form, "annual") || inFormGroup(form, "quarterly")
+}
+
+// Returns the latest filed periodic fact matching the given condition, or nil
+func latestFact(entries []UnitEntry, match func(e *UnitEntry) bool) *UnitEntry {
+	var latest *UnitEntry
+	for i := range entries {
+		e := &entries[i]
+		if !periodicForm(e.Form) || !match(e) {
+			continue
+		}
+		if latest == nil || e.Filed > latest.Filed {
+			latest = e
+		}
+	}
+	return latest
+}
+
+// Returns the date n days after the given YYYY-MM-DD date
+func addDays(date string, n int) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, n).Format("2006-01-02")
+}
+
+// Describes the period of a fact in the basis of a derived value
+func span(e *UnitEntry) string {
+	return e.PeriodStart + ".." + e.PeriodEnd
+}
+
+// Adds the values of add and subtracts the values of sub. Returns false if
+// a value is not a number.
+func sumValues(add []*UnitEntry, sub []*UnitEntry) (json.Number, bool) {
+	total := new(big.Rat)
+	for _, e := range add {
+		v, ok := new(big.Rat).SetString(e.Value.String())
+		if !ok {
+			return "", false
+		}
+		total.Add(total, v)
+	}
+	for _, e := range sub {
+		v, ok := new(big.Rat).SetString(e.Value.String())
+		if !ok {
+			return "", false
+		}
+		total.Sub(total, v)
+	}
+	return json.Number(formatRat(total)), true
+}
+
+// Returns the latest filed of the longest duration facts ending on end
+func longestEnding(entries []UnitEntry, end string) *UnitEntry {
+	var longest *UnitEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.PeriodEnd == end && e.PeriodStart != "" && periodicForm(e.Form) &&
+			(longest == nil || durationDays(e) > durationDays(longest)) {
+			longest = e
+		}
+	}
+	if longest == nil {
+		return nil
+	}
+	return latestFact(entries, func(e *UnitEntry) bool {
+		return e.PeriodEnd == end && e.PeriodStart == longest.PeriodStart
+	})
+}
+
+// Builds a derived value for the period from start to end out of the facts
+// it was computed from. The latest of the facts supplies the filing.
+func derivedValue(value json.Number, start, end string, basis string, from ...*UnitEntry) *ReportValue {
+	d := &ReportValue{Derived: true, Basis: basis}
+	for _, e := range from {
+		if e.Filed >= d.Filed {
+			d.Accession, d.Form, d.Filed = e.Accession, e.Form, e.Filed
+		}
+	}
+	d.PeriodStart, d.PeriodEnd, d.Value = start, end, value
+	return d
+}
+
+// Derives the discrete value of a quarter of a flow concept that is only
+// reported year-to-date: Q2 and Q3 are the difference of two year-to-date
+// values from the 10-Qs, and Q4 is the fiscal year less the nine months
+// year-to-date. Returns nil if the values needed are not reported.
+func discreteQuarter(entries []UnitEntry, p Period) *ReportValue {
+	// The longest period ending with the quarter: year-to-date or the year
+	ytd := longestEnding(entries, p.End)
+	if ytd == nil {
+		return nil
+	}
+	// The year-to-date value ending with the previous quarter
+	var prior *UnitEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.PeriodStart == ytd.PeriodStart && e.PeriodEnd < ytd.PeriodEnd && periodicForm(e.Form) &&
+			(prior == nil || e.PeriodEnd > prior.PeriodEnd) {
+			prior = e
+		}
+	}
+	if prior == nil {
+		return nil
+	}
+	prior = latestFact(entries, func(e *UnitEntry) bool {
+		return e.PeriodStart == prior.PeriodStart && e.PeriodEnd == prior.PeriodEnd
+	})
+	quarter := &UnitEntry{PeriodStart: addDays(prior.PeriodEnd, 1), PeriodEnd: p.End}
+	if !coversFiscalPeriod(quarter, p.ForPeriod) {
+		return nil
+	}
+	value, ok := sumValues([]*UnitEntry{ytd}, []*UnitEntry{prior})
+	if !ok {
+		return nil
+	}
+	d := derivedValue(value, quarter.PeriodStart, quarter.PeriodEnd, span(ytd)+" - "+span(prior), ytd, prior)
+	d.FiscalYear, d.ForPeriod = p.FiscalYear, p.ForPeriod
+	return d
+}
+
+// Computes the trailing twelve months value of a flow concept ending with
+// each quarterly column of the report: the reported 12 month value when
+// there is one, otherwise year-to-date plus the prior fiscal year less the
+// prior year-to-date. Annual columns, and instant concepts, have none.
+func (g *GetConfig) trailingTwelveMonths(entries []UnitEntry) []*ReportValue {
+	ttm := make([]*ReportValue, len(g.periods))
+	found := false
+	for col, p := range g.periods {
+		if p.ForPeriod == "FY" {
+			continue
+		}
+		year := latestFact(entries, func(e *UnitEntry) bool {
+			return e.PeriodEnd == p.End && e.PeriodStart != "" && coversFiscalPeriod(e, "FY")
+		})
+		if year != nil {
+			ttm[col] = &ReportValue{UnitEntry: *year}
+			ttm[col].FiscalYear, ttm[col].ForPeriod = p.FiscalYear, "TTM"
+			found = true
+			continue
+		}
+		ytd := longestEnding(entries, p.End)
+		if ytd == nil {
+			continue
+		}
+		// The fiscal year ending the day before the year-to-date period began
+		prevYear := latestFact(entries, func(e *UnitEntry) bool {
+			return e.PeriodEnd == addDays(ytd.PeriodStart, -1) && coversFiscalPeriod(e, "FY")
+		})
+		if prevYear == nil {
+			continue
+		}
+		// The same year-to-date period of that fiscal year, give or take the
+		// week a 52/53-week year shifts it by
+		priorYTD := latestFact(entries, func(e *UnitEntry) bool {
+			return e.PeriodStart == prevYear.PeriodStart && abs(durationDays(e)-durationDays(ytd)) <= 7
+		})
+		if priorYTD == nil {
+			continue
+		}
+		value, ok := sumValues([]*UnitEntry{ytd, prevYear}, []*UnitEntry{priorYTD})
+		if !ok {
+			continue
+		}
+		basis := span(ytd) + " + " + span(prevYear) + " - " + span(priorYTD)
+		ttm[col] = derivedValue(value, addDays(priorYTD.PeriodEnd, 1), p.End, basis, ytd, prevYear, priorYTD)
+		ttm[col].FiscalYear, ttm[col].ForPeriod = p.FiscalYear, "TTM"
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return ttm
+}
@@ -0,0 +1,212 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Columns of a report flattened into one row per value
+var reportColumns = []string{"section", "line", "concept", "label", "period", "start", "end", "value", "unit", "form", "accession", "filed", "derived"}
+
+// Columns of a fact series, one row per reported fact
+var factColumns = []string{"taxonomy", "concept", "label", "unit", "start", "end", "value", "fy", "fp", "form", "filed", "accession", "frame", "fiscal_year", "fiscal_period", "calendar"}
+
+// Columns of a concept listing, one row per concept and unit
+var conceptColumns = []string{"taxonomy", "concept", "label", "unit", "facts", "first_end", "last_end"}
+
+// Writes rows as CSV, or tab separated with format "tsv". Fields holding the
+// separator, quotes or newlines are quoted either way.
+func writeDelimited(w io.Writer, format string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if format == "tsv" {
+		cw.Comma = '\t'
+	}
+	return cw.WriteAll(rows)
+}
+
+// Returns the report flattened into a header and one row per reported value:
+// the standardized lines, the ratios, then the line items of each section,
+// in the same order as the pivoted Rows. Trailing twelve months values are
+// lines of their own.
+func (r *FinancialStatement) FlatRows() [][]string {
+	rows := [][]string{reportColumns}
+	values := func(section, line, concept, unit string, data []*ReportValue) {
+		for col, v := range data {
+			if v == nil {
+				continue
+			}
+			c := concept
+			if v.Concept != "" {
+				c = v.Concept
+			}
+			rows = append(rows, []string{
+				section, line, c, r.labels[c], r.Periods[col].Label, v.PeriodStart, v.PeriodEnd,
+				v.Value.String(), unit, v.Form, v.Accession, v.Filed, strconv.FormatBool(v.Derived),
+			})
+		}
+	}
+	for _, sl := range r.Standardized {
+		values("Standardized/"+sl.Statement, sl.Name, "", sl.Unit, sl.Data)
+		if sl.TTM != nil {
+			values("Standardized/"+sl.Statement, sl.Name+" (TTM)", "", sl.Unit, sl.TTM)
+		}
+	}
+	for _, ratio := range r.Ratios {
+		for col, v := range ratio.Values {
+			if v.Value == nil {
+				continue
+			}
+			rows = append(rows, []string{
+				"Ratios/" + ratio.Category, ratio.Name, "", ratio.Formula, r.Periods[col].Label, "", r.Periods[col].End,
+				strconv.FormatFloat(*v.Value, 'g', -1, 64), "", "", "", "", "true",
+			})
+		}
+	}
+	for _, s := range r.Sections() {
+		for _, li := range *s.Items {
+			values(s.Name, li.Tag, li.Concept, li.Unit, li.Data)
+			if li.TTM != nil {
+				values(s.Name, li.Tag+" (TTM)", li.Concept, li.Unit, li.TTM)
+			}
+		}
+	}
+	return rows
+}
+
+// Returns the facts of the given concepts, or all concepts if none are
+// given, as a header and one row per fact. Rows are sorted by taxonomy,
+// concept, unit, period and filing so exports diff cleanly. Each fact is
+// placed on the company's fiscal calendar by its own period dates.
+func factRows(f *CompanyFacts, fc fiscalCalendar, concepts []string, forms map[string]bool) [][]string {
+	wanted := make(map[string]bool, len(concepts))
+	for _, c := range concepts {
+		wanted[c] = true
+	}
+	rows := make([][]string, 0)
+	for taxonomy, data := range f.taxonomies() {
+		for concept, fact := range data {
+			if len(wanted) > 0 && !wanted[concept] {
+				continue
+			}
+			for unit, entries := range fact.Units.byUnit() {
+				for i := range entries {
+					e := &entries[i]
+					if len(forms) > 0 && !formSelected(forms, e.Form, true) {
+						continue
+					}
+					p := fc.align(e)
+					rows = append(rows, []string{
+						taxonomy, concept, fact.Label, unit, e.PeriodStart, e.PeriodEnd, e.Value.String(),
+						strconv.Itoa(e.FiscalYear), e.ForPeriod, e.Form, e.Filed, e.Accession, e.Frame,
+						strconv.Itoa(p.FiscalYear), p.FiscalPeriod, p.Calendar,
+					})
+				}
+			}
+		}
+	}
+	sortRows(rows, 0, 1, 3, 5, 4, 10, 11)
+	return append([][]string{factColumns}, rows...)
+}
+
+// Returns a header and one row per concept and unit with the number of
+// facts reported and the period ends they span, sorted by concept
+func conceptRows(f *CompanyFacts) [][]string {
+	rows := make([][]string, 0)
+	for taxonomy, data := range f.taxonomies() {
+		for concept, fact := range data {
+			for unit, entries := range fact.Units.byUnit() {
+				if len(entries) == 0 {
+					continue
+				}
+				first, last := entries[0].PeriodEnd, entries[0].PeriodEnd
+				for _, e := range entries {
+					first, last = min(first, e.PeriodEnd), max(last, e.PeriodEnd)
+				}
+				rows = append(rows, []string{taxonomy, concept, fact.Label, unit, strconv.Itoa(len(entries)), first, last})
+			}
+		}
+	}
+	sortRows(rows, 0, 1, 3)
+	return append([][]string{conceptColumns}, rows...)
+}
+
+// Sorts rows by the given columns, in order of precedence
+func sortRows(rows [][]string, columns ...int) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, c := range columns {
+			if rows[i][c] != rows[j][c] {
+				return rows[i][c] < rows[j][c]
+			}
+		}
+		return false
+	})
+}
+
+// Holds the arguments of the facts subcommand
+type FactsConfig struct {
+	GetConfig
+	Concepts string // comma separated concepts, empty for all
+	Forms    string // comma separated forms or groups, empty for all
+	List     bool   // list the concepts reported instead of their facts
+	Output   string // csv, tsv or json
+}
+
+func (fc *FactsConfig) HandleFacts() {
+	if fc.Ticker == "" && fc.CIK == "" {
+		fmt.Println("Error: expected a ticker, e.g. edgar facts AAPL -concepts Revenues. Exiting...")
+		os.Exit(1)
+	}
+	forms, err := formSet(fc.Forms)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	c := checkConfig()
+	fc.resolveCIK(c)
+	facts := fc.loadCompanyFacts(c, assembleUrl(fc.CIK, companyFacts))
+
+	var rows [][]string
+	if fc.List {
+		rows = conceptRows(facts)
+	} else {
+		var concepts []string
+		if fc.Concepts != "" {
+			for _, name := range strings.Split(fc.Concepts, ",") {
+				concepts = append(concepts, strings.TrimSpace(name))
+			}
+		}
+		rows = factRows(facts, fc.loadFiscalCalendar(c, facts), concepts, forms)
+	}
+
+	switch fc.Output {
+	case "json":
+		// One object per row, keyed by column
+		objects := make([]map[string]string, 0, len(rows)-1)
+		for _, row := range rows[1:] {
+			o := make(map[string]string, len(row))
+			for i, v := range row {
+				o[rows[0][i]] = v
+			}
+			objects = append(objects, o)
+		}
+		b, err := json.MarshalIndent(objects, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal facts! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	default:
+		err := writeDelimited(os.Stdout, fc.Output, rows)
+		if err != nil {
+			fmt.Printf("Error: could not write facts! (%v)\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d rows from the company facts of %s\n", len(rows)-1, facts.EntityName)
+}
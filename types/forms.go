@@ -0,0 +1,178 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The EDGAR form types edgar knows, with a short description
+var formCatalog = map[string]string{
+	"10-K":     "Annual report",
+	"10-K405":  "Annual report, Regulation S-K Item 405 box checked (before 2003)",
+	"10-KT":    "Annual transition report",
+	"10-Q":     "Quarterly report",
+	"10-QT":    "Quarterly transition report",
+	"10-D":     "Distribution report of an asset-backed issuer",
+	"20-F":     "Annual report of a foreign private issuer",
+	"40-F":     "Annual report of a Canadian issuer",
+	"6-K":      "Current report of a foreign private issuer",
+	"8-K":      "Current report",
+	"8-K12B":   "Current report of a successor issuer",
+	"11-K":     "Annual report of an employee stock plan",
+	"SD":       "Specialized disclosure report (conflict minerals)",
+	"ARS":      "Annual report to security holders",
+	"3":        "Initial statement of beneficial ownership",
+	"4":        "Statement of changes in beneficial ownership",
+	"5":        "Annual statement of changes in beneficial ownership",
+	"144":      "Notice of proposed sale of securities",
+	"D":        "Notice of exempt offering of securities",
+	"S-1":      "Registration statement",
+	"S-3":      "Shelf registration statement",
+	"S-4":      "Registration statement for business combinations",
+	"S-8":      "Registration statement for employee benefit plans",
+	"F-1":      "Registration statement of a foreign private issuer",
+	"424B2":    "Prospectus",
+	"424B3":    "Prospectus",
+	"424B4":    "Prospectus",
+	"424B5":    "Prospectus supplement",
+	"FWP":      "Free writing prospectus",
+	"DEF 14A":  "Definitive proxy statement",
+	"DEFA14A":  "Additional proxy soliciting materials",
+	"DEFM14A":  "Definitive proxy statement for a merger",
+	"PRE 14A":  "Preliminary proxy statement",
+	"SC 13D":   "Beneficial ownership report of an active investor",
+	"SC 13G":   "Beneficial ownership report of a passive investor",
+	"SC TO-T":  "Third party tender offer statement",
+	"13F-HR":   "Institutional investment manager holdings report",
+	"N-CSR":    "Certified shareholder report of a registered fund",
+	"N-CSRS":   "Certified semi-annual shareholder report of a registered fund",
+	"N-CEN":    "Annual report of a registered fund",
+	"N-PORT-P": "Monthly portfolio holdings of a registered fund",
+	"497":      "Definitive materials of a registered fund",
+	"497K":     "Summary prospectus of a registered fund",
+	"485BPOS":  "Post-effective amendment of a fund registration",
+	"CORRESP":  "Correspondence from the filer to the SEC staff",
+	"UPLOAD":   "Correspondence from the SEC staff to the filer",
+}
+
+// Other names for forms in the catalog, keyed by formKey
+var formAliases = map[string]string{
+	"PROXY":   "DEF 14A",
+	"DEF14A":  "DEF 14A",
+	"PRE14A":  "PRE 14A",
+	"13D":     "SC 13D",
+	"13G":     "SC 13G",
+	"SC13D":   "SC 13D",
+	"SC13G":   "SC 13G",
+	"13F":     "13F-HR",
+	"FORM3":   "3",
+	"FORM4":   "4",
+	"FORM5":   "5",
+	"FORM144": "144",
+}
+
+// Named groups of forms, accepted wherever a form is
+var formGroups = map[string][]string{
+	"annual":       {"10-K", "10-K405", "10-KT", "20-F", "40-F"},
+	"quarterly":    {"10-Q", "10-QT"},
+	"current":      {"8-K", "6-K"},
+	"insider":      {"3", "4", "5"},
+	"proxy":        {"DEF 14A", "DEFA14A", "PRE 14A"},
+	"ownership":    {"SC 13D", "SC 13G"},
+	"registration": {"S-1", "S-3", "S-4", "S-8", "F-1"},
+}
+
+// Normalizes a form for lookup: "10k", "10-K" and "10 K" are the same form
+func formKey(form string) string {
+	return strings.NewReplacer("-", "", " ", "", "_", "").Replace(strings.ToUpper(strings.TrimSpace(form)))
+}
+
+// Returns the catalog form a name or alias refers to
+func canonicalForm(name string) (string, bool) {
+	key := formKey(name)
+	if form, ok := formAliases[key]; ok {
+		return form, true
+	}
+	for form := range formCatalog {
+		if formKey(form) == key {
+			return form, true
+		}
+	}
+	return "", false
+}
+
+// Splits an amendment such as 10-K/A into its form and true
+func baseForm(form string) (string, bool) {
+	return strings.CutSuffix(form, "/A")
+}
+
+// Reports whether a form, or the form it amends, is in the group
+func inFormGroup(form, group string) bool {
+	base, _ := baseForm(form)
+	for _, f := range formGroups[group] {
+		if f == base {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolves a comma separated list of forms, aliases and groups, such as
+// "annual,8-K", into the set of forms it selects. A "/A" suffix, as in
+// "10-K/A" or "annual/A", selects the amendments of the forms instead.
+// Unknown forms are an error suggesting the closest known name.
+func formSet(list string) (map[string]bool, error) {
+	forms := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		base, amended := baseForm(strings.ToUpper(name))
+		var selected []string
+		if group, ok := formGroups[strings.ToLower(base)]; ok {
+			selected = group
+		} else if form, ok := canonicalForm(base); ok {
+			selected = []string{form}
+		} else {
+			return nil, unknownForm(name)
+		}
+		for _, form := range selected {
+			if amended {
+				form += "/A"
+			}
+			forms[form] = true
+		}
+	}
+	return forms, nil
+}
+
+// Describes an unknown form, suggesting the closest form or group
+func unknownForm(name string) error {
+	names := make([]string, 0, len(formCatalog)+len(formGroups))
+	for form := range formCatalog {
+		names = append(names, form)
+	}
+	sort.Strings(names)
+	groups := make([]string, 0, len(formGroups))
+	for group := range formGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	names = append(names, groups...)
+	if s := closest(name, names); s != "" {
+		return fmt.Errorf("unknown form %q, did you mean %s?", name, s)
+	}
+	return fmt.Errorf("unknown form %q, expected a form such as 10-K or a group: %s", name, strings.Join(groups, ", "))
+}
+
+// Reports whether a filing of the given form is selected. Amendments of
+// selected forms are too when amendments is set.
+func formSelected(forms map[string]bool, form string, amendments bool) bool {
+	if forms[form] {
+		return true
+	}
+	base, amended := baseForm(form)
+	return amended && amendments && forms[base]
+}
@@ -0,0 +1,312 @@
+package types
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fullIndex  = "https://www.sec.gov/Archives/edgar/full-index/"
+	dailyIndex = "https://www.sec.gov/Archives/edgar/daily-index/"
+	archives   = "https://www.sec.gov/Archives/"
+)
+
+// An IndexEntry is a single filing listed in an EDGAR form, master or company
+// index file
+type IndexEntry struct {
+	CIK         int    `json:"cik"`
+	CompanyName string `json:"companyName"`
+	FormType    string `json:"formType"`
+	DateFiled   string `json:"dateFiled"` // YYYY-MM-DD
+	FileName    string `json:"fileName"`  // e.g. edgar/data/320193/0000320193-24-000006.txt
+}
+
+// Returns the accession number of the filing, e.g. 0000320193-24-000006
+func (e *IndexEntry) Accession() string {
+	return strings.TrimSuffix(path.Base(e.FileName), ".txt")
+}
+
+// Returns the URL of the complete submission text file
+func (e *IndexEntry) URL() string {
+	return archives + e.FileName
+}
+
+// Parses an EDGAR form.idx, master.idx or company.idx file. The layout is
+// detected from the column header, and gzip or zip compressed input is
+// decompressed transparently.
+func ParseIndex(r io.Reader) ([]IndexEntry, error) {
+	r, err := decompressIndex(r)
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	// Skip the preamble until the column header and the dashed line below it
+	var header string
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "---") && header != "" {
+			break
+		}
+		if strings.TrimSpace(line) != "" {
+			header = line
+		}
+	}
+	if header == "" {
+		return nil, fmt.Errorf("index header not found")
+	}
+	parse, err := indexLineParser(header)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]IndexEntry, 0)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %q", err, line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// Returns a parser for the index layout described by the header line
+func indexLineParser(header string) (func(string) (IndexEntry, error), error) {
+	switch {
+	case strings.Contains(header, "|"):
+		return parseMasterLine, nil
+	case strings.HasPrefix(header, "Form Type"):
+		split := strings.Index(header, "Company Name")
+		return func(line string) (IndexEntry, error) {
+			return parseFixedWidthLine(line, split, true)
+		}, nil
+	case strings.HasPrefix(header, "Company Name"):
+		split := strings.Index(header, "Form Type")
+		return func(line string) (IndexEntry, error) {
+			return parseFixedWidthLine(line, split, false)
+		}, nil
+	}
+	return nil, fmt.Errorf("unrecognized index header %q", header)
+}
+
+// Parses a master.idx line: CIK|Company Name|Form Type|Date Filed|Filename
+func parseMasterLine(line string) (IndexEntry, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 5 {
+		return IndexEntry{}, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	cik, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	date, err := normalizeIndexDate(fields[3])
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	return IndexEntry{
+		CIK:         cik,
+		CompanyName: strings.TrimSpace(fields[1]),
+		FormType:    strings.TrimSpace(fields[2]),
+		DateFiled:   date,
+		FileName:    strings.TrimSpace(fields[4]),
+	}, nil
+}
+
+// Parses a form.idx or company.idx line. The last three columns (CIK, date
+// filed and file name) never contain spaces, so they are read from the right.
+// The first two columns are split at the offset of the second header column.
+func parseFixedWidthLine(line string, split int, formFirst bool) (IndexEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || split <= 0 || split >= len(line) {
+		return IndexEntry{}, fmt.Errorf("malformed index line")
+	}
+	n := len(fields)
+	cik, err := strconv.Atoi(fields[n-3])
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	date, err := normalizeIndexDate(fields[n-2])
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	e := IndexEntry{CIK: cik, DateFiled: date, FileName: fields[n-1]}
+	// Everything before the CIK column holds the first two columns
+	rest := line[:strings.LastIndex(line, fields[n-3]+" ")]
+	if len(rest) < split {
+		return IndexEntry{}, fmt.Errorf("malformed index line")
+	}
+	first, second := strings.TrimSpace(rest[:split]), strings.TrimSpace(rest[split:])
+	if formFirst {
+		e.FormType, e.CompanyName = first, second
+	} else {
+		e.CompanyName, e.FormType = first, second
+	}
+	return e, nil
+}
+
+// Full indexes use YYYY-MM-DD, daily indexes use YYYYMMDD
+func normalizeIndexDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", s)
+}
+
+// Wraps r in a decompressor if the content is gzip or zip compressed
+func decompressIndex(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		// zip needs random access, so read the whole archive into memory
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		if len(zr.File) == 0 {
+			return nil, fmt.Errorf("empty zip archive")
+		}
+		return zr.File[0].Open()
+	}
+	return br, nil
+}
+
+// Holds the options for the index subcommand
+type IndexConfig struct {
+	Date     string // YYYY-MM-DD, selects a daily index
+	Quarter  string // YYYYQn, selects a full (quarterly) index
+	Kind     string // form, master or company
+	Form     string // comma separated form types or groups to keep, empty keeps all
+	File     string // parse a local index file instead of downloading one
+	JSON     bool
+	Download bool
+}
+
+// Returns the URL of the index file selected by Date or Quarter
+func (x *IndexConfig) indexUrl() (string, error) {
+	if x.Date != "" {
+		t, err := time.Parse("2006-01-02", x.Date)
+		if err != nil {
+			return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", x.Date)
+		}
+		qtr := (int(t.Month())-1)/3 + 1
+		return fmt.Sprintf("%s%d/QTR%d/%s.%s.idx", dailyIndex, t.Year(), qtr, x.Kind, t.Format("20060102")), nil
+	}
+	var year, qtr int
+	_, err := fmt.Sscanf(strings.ToUpper(x.Quarter), "%4dQ%1d", &year, &qtr)
+	if err != nil || qtr < 1 || qtr > 4 {
+		return "", fmt.Errorf("invalid quarter %q, expected YYYYQn", x.Quarter)
+	}
+	return fmt.Sprintf("%s%d/QTR%d/%s.idx", fullIndex, year, qtr, x.Kind), nil
+}
+
+// Keeps the entries whose form type is one of the forms, or every entry if
+// no forms are given
+func filterForms(entries []IndexEntry, forms map[string]bool) []IndexEntry {
+	if len(forms) == 0 {
+		return entries
+	}
+	kept := make([]IndexEntry, 0)
+	for _, e := range entries {
+		if forms[strings.ToUpper(e.FormType)] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Reads the index entries from the local file or the SEC archives
+func (x *IndexConfig) loadEntries() ([]IndexEntry, error) {
+	if x.File != "" {
+		f, err := os.Open(x.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseIndex(f)
+	}
+	if x.Date == "" && x.Quarter == "" {
+		return nil, fmt.Errorf("expected -date or -quarter")
+	}
+	url, err := x.indexUrl()
+	if err != nil {
+		return nil, err
+	}
+	c := checkConfig()
+	body, err := c.makeSecRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(bytes.NewReader(body))
+}
+
+func (x *IndexConfig) HandleIndex() {
+	forms, err := formSet(x.Form)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	entries, err := x.loadEntries()
+	if err != nil {
+		fmt.Printf("Error: could not read index! (%v)\n", err)
+		os.Exit(1)
+	}
+	entries = filterForms(entries, forms)
+
+	for _, e := range entries {
+		if x.JSON {
+			b, err := json.Marshal(e)
+			if err != nil {
+				fmt.Printf("Error: could not marshal index entry! (%v)\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(b))
+		} else {
+			fmt.Printf("%s\t%s\t%d\t%s\t%s\n", e.DateFiled, e.FormType, e.CIK, e.CompanyName, e.URL())
+		}
+	}
+
+	if x.Download {
+		urls := make([]string, len(entries))
+		for i, e := range entries {
+			urls[i] = e.URL()
+		}
+		name := x.Date + x.Quarter
+		if name == "" {
+			name = strings.TrimSuffix(path.Base(x.File), path.Ext(x.File))
+		}
+		dir := "app/index/" + name + "/"
+		err = downloadFiles(urls, dir, checkConfig())
+		if err != nil {
+			fmt.Printf("Error: Failed to download files! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Downloaded %d filing(s) to %s\n", len(urls), dir)
+	}
+}
@@ -0,0 +1,350 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// An InlineFact is a fact tagged in an inline XBRL document. Numeric facts
+// carry their value in the embedded UnitEntry, in the same shape as the
+// companyfacts API; ix:nonNumeric facts carry Text instead.
+type InlineFact struct {
+	Taxonomy   string            `json:"taxonomy"` // namespace prefix, e.g. us-gaap or a company extension
+	Concept    string            `json:"concept"`
+	Unit       string            `json:"unit,omitempty"`
+	Decimals   string            `json:"decimals,omitempty"`
+	Dimensions map[string]string `json:"dimensions,omitempty"` // axis -> member
+	Text       string            `json:"text,omitempty"`
+	UnitEntry
+}
+
+// An xbrli:context from the ix:header
+type ixContext struct {
+	start, end string
+	dimensions map[string]string
+}
+
+// Reads the contexts and units declared in the ix:resources of the header
+func ixResources(doc *html.Node) (map[string]*ixContext, map[string]string) {
+	contexts := make(map[string]*ixContext)
+	units := make(map[string]string)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "xbrli:context":
+				contexts[attr(n, "id")] = readContext(n)
+				return
+			case "xbrli:unit":
+				units[attr(n, "id")] = readUnit(n)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return contexts, units
+}
+
+func readContext(n *html.Node) *ixContext {
+	ctx := &ixContext{dimensions: make(map[string]string)}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "xbrli:startdate":
+				ctx.start = nodeText(n)
+			case "xbrli:enddate", "xbrli:instant":
+				ctx.end = nodeText(n)
+			case "xbrldi:explicitmember", "xbrldi:typedmember":
+				ctx.dimensions[attr(n, "dimension")] = nodeText(n)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return ctx
+}
+
+// Returns the unit as companyfacts names it: "USD", "shares" or "USD/shares"
+func readUnit(n *html.Node) string {
+	measures := make([]string, 0)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "xbrli:measure" {
+			m := nodeText(n)
+			if i := strings.Index(m, ":"); i >= 0 {
+				m = m[i+1:]
+			}
+			measures = append(measures, m)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(measures, "/")
+}
+
+// Returns the raw text of a node, hidden content included
+func rawText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(spaceRun.ReplaceAllString(b.String(), " "))
+}
+
+// Converts the displayed text of an ix:nonFraction to its value, applying
+// the transformation format, scale and sign
+func ixValue(text, format, scale, sign string) (string, error) {
+	format = strings.ToLower(format[strings.Index(format, ":")+1:])
+	var digits string
+	switch {
+	case strings.Contains(format, "zero") || strings.Contains(format, "dash") || strings.Trim(text, "-–— ") == "":
+		digits = "0"
+	case format == "numwordsen":
+		n, ok := numberWords(text)
+		if !ok {
+			return "", fmt.Errorf("invalid number words %q", text)
+		}
+		digits = strconv.FormatInt(n, 10)
+	case strings.Contains(format, "comma-decimal") || strings.Contains(format, "numcommadecimal"):
+		// European style: 1.234,5
+		digits = strings.NewReplacer(".", "", " ", "", " ", "", ",", ".").Replace(text)
+	default:
+		digits = strings.NewReplacer(",", "", " ", "", " ", "").Replace(text)
+	}
+	v, ok := new(big.Rat).SetString(digits)
+	if !ok {
+		return "", fmt.Errorf("invalid number %q", text)
+	}
+	if scale != "" {
+		exp, err := strconv.Atoi(scale)
+		if err != nil {
+			return "", fmt.Errorf("invalid scale %q", scale)
+		}
+		pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil))
+		if exp >= 0 {
+			v.Mul(v, pow)
+		} else {
+			v.Quo(v, pow)
+		}
+	}
+	if sign == "-" {
+		v.Neg(v)
+	}
+	return formatRat(v), nil
+}
+
+// English number words as written out in filings, e.g. "three" directors
+// or "no" impairment
+var numberWordValues = map[string]int64{
+	"no": 0, "none": 0, "zero": 0, "one": 1, "two": 2, "three": 3, "four": 4,
+	"five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	"eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60,
+	"seventy": 70, "eighty": 80, "ninety": 90,
+}
+
+var numberWordScales = map[string]int64{
+	"thousand": 1e3, "million": 1e6, "billion": 1e9, "trillion": 1e12,
+}
+
+// Converts a number written in English words, as tagged with the
+// ixt-sec:numwordsen format, e.g. "one hundred twenty-five" to 125
+func numberWords(text string) (int64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == ' ' || r == '-' || r == ',' || r == ' '
+	})
+	var total, current int64
+	found := false
+	for _, w := range words {
+		if v, ok := numberWordValues[w]; ok {
+			current += v
+		} else if w == "hundred" {
+			current *= 100
+		} else if scale, ok := numberWordScales[w]; ok {
+			total += current * scale
+			current = 0
+		} else if w != "and" {
+			return 0, false
+		}
+		found = found || w != "and"
+	}
+	return total + current, found
+}
+
+// Formats a value as an integer when it is one, otherwise as a decimal
+func formatRat(v *big.Rat) string {
+	if v.IsInt() {
+		return v.Num().String()
+	}
+	return strings.TrimRight(v.FloatString(10), "0")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Extracts every ix:nonFraction and ix:nonNumeric fact from an inline XBRL
+// document, hidden facts included. The document type and fiscal period tagged
+// in the dei namespace are copied onto every fact. Duplicate facts, which
+// appear whenever a number is repeated in the text, are reported once. Facts
+// that cannot be read are skipped and returned as warnings.
+func ExtractInlineFacts(doc *html.Node) ([]InlineFact, []error) {
+	contexts, units := ixResources(doc)
+
+	// ix:nonNumeric text may continue in ix:continuation elements
+	continuations := make(map[string]*html.Node)
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "ix:continuation" {
+			continuations[attr(n, "id")] = n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(doc)
+
+	facts := make([]InlineFact, 0)
+	seen := make(map[string]bool)
+	warnings := make([]error, 0)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "ix:nonfraction" || n.Data == "ix:nonnumeric") {
+			f, err := inlineFact(n, contexts, units, continuations)
+			if err != nil {
+				warnings = append(warnings, fmt.Errorf("%s in context %s: %v", attr(n, "name"), attr(n, "contextref"), err))
+			} else if f != nil {
+				key := fmt.Sprint(f.Taxonomy, f.Concept, attr(n, "contextref"), f.Unit, f.Value, f.Text)
+				if !seen[key] {
+					seen[key] = true
+					facts = append(facts, *f)
+				}
+			}
+			// Facts may nest; an ix:nonNumeric text block often contains
+			// the numbers it discusses
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	// Copy the document's form and fiscal period onto every fact
+	var form, fp string
+	var fy int
+	for _, f := range facts {
+		switch f.Taxonomy + ":" + f.Concept {
+		case "dei:DocumentType":
+			form = f.Text
+		case "dei:DocumentFiscalPeriodFocus":
+			fp = f.Text
+		case "dei:DocumentFiscalYearFocus":
+			fy, _ = strconv.Atoi(f.Text)
+		}
+	}
+	for i := range facts {
+		facts[i].Form, facts[i].ForPeriod, facts[i].FiscalYear = form, fp, fy
+	}
+	sort.SliceStable(facts, func(i, j int) bool {
+		if facts[i].Taxonomy != facts[j].Taxonomy {
+			return facts[i].Taxonomy < facts[j].Taxonomy
+		}
+		return facts[i].Concept < facts[j].Concept
+	})
+	return facts, warnings
+}
+
+// Builds the fact tagged by an ix:nonFraction or ix:nonNumeric element.
+// Returns nil for facts explicitly set to nil.
+func inlineFact(n *html.Node, contexts map[string]*ixContext, units map[string]string, continuations map[string]*html.Node) (*InlineFact, error) {
+	name := attr(n, "name")
+	f := &InlineFact{Concept: name, Decimals: attr(n, "decimals")}
+	if i := strings.Index(name, ":"); i >= 0 {
+		f.Taxonomy, f.Concept = name[:i], name[i+1:]
+	}
+	ctx, ok := contexts[attr(n, "contextref")]
+	if !ok {
+		return nil, fmt.Errorf("unknown context %q", attr(n, "contextref"))
+	}
+	f.PeriodStart, f.PeriodEnd = ctx.start, ctx.end
+	if len(ctx.dimensions) > 0 {
+		f.Dimensions = ctx.dimensions
+	}
+	if attr(n, "xsi:nil") == "true" {
+		return nil, nil
+	}
+
+	if n.Data == "ix:nonnumeric" {
+		text := rawText(n)
+		for next := attr(n, "continuedat"); next != ""; {
+			c, ok := continuations[next]
+			if !ok {
+				break
+			}
+			text += " " + rawText(c)
+			next = attr(c, "continuedat")
+		}
+		f.Text = text
+		return f, nil
+	}
+
+	f.Unit = units[attr(n, "unitref")]
+	v, err := ixValue(rawText(n), attr(n, "format"), attr(n, "scale"), attr(n, "sign"))
+	if err != nil {
+		return nil, err
+	}
+	f.Value = json.Number(v)
+	return f, nil
+}
+
+// Writes the inline XBRL facts of the document as JSON next to the source
+func (p *ParseConfig) writeInlineFacts(doc *html.Node) {
+	facts, warnings := ExtractInlineFacts(doc)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: skipped fact %v\n", w)
+	}
+	if len(facts) == 0 {
+		fmt.Printf("Error: no inline XBRL facts found in %s!\n", p.File)
+		os.Exit(1)
+	}
+	b, err := json.MarshalIndent(facts, "", "	")
+	if err != nil {
+		fmt.Printf("Error: could not marshal facts! (%v)\n", err)
+		os.Exit(1)
+	}
+	out := p.outputBase() + ".facts.json"
+	err = os.WriteFile(out, b, 0666)
+	if err != nil {
+		fmt.Printf("Error: could not write file! (%v)\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Extracted %d fact(s) from %s to %s\n", len(facts), p.File, out)
+}
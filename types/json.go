@@ -6,14 +6,18 @@ import "encoding/json"
 // the data.sec.gov/submissions/ API endpoint. The JSON response contains
 // the information needed to download documents from the SEC site.
 type CompanyFilings struct {
-	Cik     string `json:"cik"`
-	Name    string `json:"name"`
-	Filings struct {
+	Cik           string `json:"cik"`
+	Name          string `json:"name"`
+	FiscalYearEnd string `json:"fiscalYearEnd"` // MMDD, e.g. "0930"
+	Filings       struct {
 		Recent struct {
 			AccessionNumber []string `json:"accessionNumber"`
 			FilingDate      []string `json:"filingDate"`
+			ReportDate      []string `json:"reportDate"`
 			Form            []string `json:"form"`
 			PrimaryDocument []string `json:"primaryDocument"`
+			IsXBRL          []int    `json:"isXBRL"`
+			IsInlineXBRL    []int    `json:"isInlineXBRL"`
 		} `json:"recent"`
 	} `json:"filings"`
 }
@@ -29,12 +33,78 @@ type Ticker struct {
 // https://data.sec.gov/api/xbrl/companyfacts/ endpoint
 // TODO: rename the data members like USGAAP, USD
 type CompanyFacts struct {
-	Cik        int    `json:"cik"`
-	EntityName string `json:"entityName"`
-	Facts      struct {
-		// get all fact names as a key with their data as the value
-		Data map[string]FactData `json:"us-gaap"`
-	} `json:"facts"`
+	Cik        int            `json:"cik"`
+	EntityName string         `json:"entityName"`
+	Facts      FactTaxonomies `json:"facts"`
+}
+
+// The facts of a company keyed by taxonomy, then concept. The reports are
+// built from the us-gaap facts in Data; the facts of every other taxonomy,
+// such as dei or ifrs-full, are kept in Other for the fact exports.
+type FactTaxonomies struct {
+	// get all fact names as a key with their data as the value
+	Data  map[string]FactData            `json:"us-gaap"`
+	Other map[string]map[string]FactData `json:"-"`
+}
+
+func (t *FactTaxonomies) UnmarshalJSON(b []byte) error {
+	var all map[string]map[string]FactData
+	err := json.Unmarshal(b, &all)
+	if err != nil {
+		return err
+	}
+	t.Data = all["us-gaap"]
+	delete(all, "us-gaap")
+	t.Other = all
+	return nil
+}
+
+func (t FactTaxonomies) MarshalJSON() ([]byte, error) {
+	all := make(map[string]map[string]FactData, len(t.Other)+1)
+	for name, data := range t.Other {
+		all[name] = data
+	}
+	if t.Data != nil {
+		all["us-gaap"] = t.Data
+	}
+	return json.Marshal(all)
+}
+
+// Returns the facts keyed by taxonomy name
+func (cf *CompanyFacts) taxonomies() map[string]map[string]FactData {
+	if cf.Facts.Data == nil {
+		cf.Facts.Data = make(map[string]FactData)
+	}
+	all := map[string]map[string]FactData{"us-gaap": cf.Facts.Data}
+	for name, data := range cf.Facts.Other {
+		all[name] = data
+	}
+	return all
+}
+
+// Returns the facts of a taxonomy, adding the taxonomy if it has none yet
+func (cf *CompanyFacts) taxonomy(name string) map[string]FactData {
+	if name == "us-gaap" {
+		return cf.taxonomies()[name]
+	}
+	if cf.Facts.Other == nil {
+		cf.Facts.Other = make(map[string]map[string]FactData)
+	}
+	data, ok := cf.Facts.Other[name]
+	if !ok {
+		data = make(map[string]FactData)
+		cf.Facts.Other[name] = data
+	}
+	return data
+}
+
+// Returns the label of each us-gaap concept
+func (cf *CompanyFacts) labels() map[string]string {
+	labels := make(map[string]string, len(cf.Facts.Data))
+	for concept, fact := range cf.Facts.Data {
+		labels[concept] = fact.Label
+	}
+	return labels
 }
 
 type FactData struct {
@@ -42,16 +112,86 @@ type FactData struct {
 	Units UnitData `json:"units"`
 }
 
+// The entries of a fact keyed by unit of measure. The units the reports use
+// have fields of their own; entries in any other unit, such as pure or EUR,
+// are kept in Other.
 type UnitData struct {
-	USD []UnitEntry `json:"USD"`
+	USD         []UnitEntry            `json:"USD"`
+	Shares      []UnitEntry            `json:"shares,omitempty"`
+	USDPerShare []UnitEntry            `json:"USD/shares,omitempty"`
+	Other       map[string][]UnitEntry `json:"-"`
+}
+
+func (u *UnitData) UnmarshalJSON(b []byte) error {
+	var all map[string][]UnitEntry
+	err := json.Unmarshal(b, &all)
+	if err != nil {
+		return err
+	}
+	*u = UnitData{}
+	for unit, entries := range all {
+		u.add(unit, entries...)
+	}
+	return nil
+}
+
+func (u UnitData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.byUnit())
+}
+
+// Returns the unit entries keyed by unit of measure
+func (u *UnitData) byUnit() map[string][]UnitEntry {
+	all := make(map[string][]UnitEntry, len(u.Other)+3)
+	for unit, entries := range u.Other {
+		all[unit] = entries
+	}
+	for unit, entries := range map[string][]UnitEntry{"USD": u.USD, "shares": u.Shares, "USD/shares": u.USDPerShare} {
+		if len(entries) > 0 {
+			all[unit] = entries
+		}
+	}
+	return all
+}
+
+// Returns the entries of the unit the concept is reported in: USD for
+// monetary concepts, USD/shares for per share amounts or shares
+func (u *UnitData) primary() ([]UnitEntry, string) {
+	switch {
+	case len(u.USD) > 0:
+		return u.USD, "USD"
+	case len(u.USDPerShare) > 0:
+		return u.USDPerShare, "USD/shares"
+	}
+	return u.Shares, "shares"
+}
+
+// Appends entries to the given unit of measure
+func (u *UnitData) add(unit string, e ...UnitEntry) {
+	switch unit {
+	case "USD":
+		u.USD = append(u.USD, e...)
+	case "shares":
+		u.Shares = append(u.Shares, e...)
+	case "USD/shares":
+		u.USDPerShare = append(u.USDPerShare, e...)
+	default:
+		if u.Other == nil {
+			u.Other = make(map[string][]UnitEntry)
+		}
+		u.Other[unit] = append(u.Other[unit], e...)
+	}
 }
 
 type UnitEntry struct {
-	PeriodEnd  string      `json:"end"`
-	Value      json.Number `json:"val"` // use json.Number because some values are floats
-	FiscalYear int         `json:"fy"`
-	ForPeriod  string      `json:"fp"`
-	Form       string      `json:"form"`
+	PeriodStart string      `json:"start,omitempty"` // empty for instant facts
+	PeriodEnd   string      `json:"end"`
+	Value       json.Number `json:"val,omitempty"` // use json.Number because some values are floats
+	Accession   string      `json:"accn,omitempty"`
+	FiscalYear  int         `json:"fy"`
+	ForPeriod   string      `json:"fp"`
+	Form        string      `json:"form"`
+	Filed       string      `json:"filed,omitempty"`
+	Frame       string      `json:"frame,omitempty"`
 }
 
 // From: https://github.com/Nneoma-Ihueze/SEC-Mapping/blob/main/xbrl_to_fin-statement_mapping.json
@@ -59,7 +199,8 @@ type UnitEntry struct {
 // Used to unmarshal json from above link. Use this info to check for these tags, since every report
 // can have slight differences in what's reported, so cannot hardcode tags
 type XBRLTags struct {
-	Tags struct {
+	Version string `json:"Version"`
+	Tags    struct {
 		BalanceSheetItems struct {
 			Assets struct {
 				CurrentAssets    []string `json:"Current Assets"`
@@ -107,6 +248,11 @@ type XBRLTags struct {
 
 // The Report struct is used to assemble a financial report for a user
 type FinancialStatement struct {
+	MappingVersion  string         // Version of the XBRL mapping the report was assembled with
+	Periods         []Period       // the columns of the report, oldest first
+	Standardized    []StandardLine // comparable across companies, see standardConcepts
+	Ratios          []Ratio        // computed from the standardized statements
+	Discrepancies   []Discrepancy  `json:",omitempty"` // set by -validate
 	IncomeStatement struct {
 		Revenue             []LineItem
 		CostOfRevenue       []LineItem
@@ -149,9 +295,26 @@ type FinancialStatement struct {
 	DerivativesAndHedging       []LineItem
 	StockAndEquityRelatedItems  []LineItem
 	OtherFinancialItems         []LineItem
+
+	labels map[string]string // label of each concept in the company facts
 }
 
 type LineItem struct {
-	Tag  string
-	Data []UnitEntry
+	Tag     string
+	Concept string         // XBRL concept the values were read from
+	Unit    string         `json:",omitempty"` // USD, shares or USD/shares
+	Data    []*ReportValue // one value per column of the report, nil where not reported
+	TTM     []*ReportValue `json:",omitempty"` // trailing twelve months ending with each quarterly column
+}
+
+// A ReportValue is the value of a line item in one column of a report. A
+// derived value was computed from other reported facts, as Basis records.
+// A restated value differs from the one the period was first reported with.
+type ReportValue struct {
+	UnitEntry
+	Concept  string      `json:"concept,omitempty"` // set on standardized lines
+	Derived  bool        `json:"derived"`
+	Basis    string      `json:"basis,omitempty"`
+	Restated bool        `json:"restated,omitempty"` // a later filing changed the value first reported
+	Original json.Number `json:"original,omitempty"` // the value first reported, set when restated
 }
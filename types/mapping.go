@@ -0,0 +1,271 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The default mapping of XBRL tags to financial statement items, versioned
+// with its Version field. Bump the version whenever the mapping changes.
+//
+//go:embed mapping/xbrl_to_fin-statement_mapping.json
+var defaultMapping []byte
+
+// Returns the path of the overrides layered over the default mapping when
+// present, mapping.json in the user's edgar configuration directory, or ""
+// if the user has no configuration directory
+func userMapping() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "edgar", "mapping.json")
+}
+
+// Overlays a mapping file on x. Buckets present in the file replace the
+// corresponding buckets of x; unknown bucket names are an error.
+func (x *XBRLTags) overlay(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	return d.Decode(x)
+}
+
+// Loads the mapping: the embedded default, overlaid with the user's
+// edgar/mapping.json if it exists and then with the given file, if any
+func loadXBRLTags(file string) (*XBRLTags, error) {
+	var xbrl XBRLTags
+	err := json.Unmarshal(defaultMapping, &xbrl)
+	if err != nil {
+		return nil, fmt.Errorf("default mapping: %v", err)
+	}
+	user := userMapping()
+	for _, name := range []string{user, file} {
+		if name == "" {
+			continue
+		}
+		b, err := os.ReadFile(name)
+		if os.IsNotExist(err) && name == user {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = xbrl.overlay(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return &xbrl, nil
+}
+
+// Gets the XBRL tags associated with income statements, balance sheets and cash flow statements
+func getXBRLTags(file string) *XBRLTags {
+	xbrl, err := loadXBRLTags(file)
+	if err != nil {
+		fmt.Printf("Error: could not load xbrl mapping! (%v)\n", err)
+		os.Exit(1)
+	}
+	return xbrl
+}
+
+// A named list of tags in the mapping
+type tagBucket struct {
+	name string
+	tags []string
+}
+
+// Returns every bucket of the mapping, named as in the mapping file
+func (x *XBRLTags) buckets() []tagBucket {
+	t := &x.Tags
+	b := &t.BalanceSheetItems
+	i := &t.IncomeStatementItems
+	c := &t.CashFlowStatementItems
+	return []tagBucket{
+		{"Current Assets", b.Assets.CurrentAssets},
+		{"Non-Current Assets", b.Assets.NonCurrentAssets},
+		{"Total Assets", b.Assets.TotalAssets},
+		{"Current Liabilities", b.Liabilities.CurrentLiabilities},
+		{"Non-Current Liabilities", b.Liabilities.NonCurrentLiabilities},
+		{"Total Liabilities", b.Liabilities.TotalLiabilities},
+		{"Equity", b.Equity},
+		{"Total Liabilities and Equity", b.TotalLiabilitiesAndEquity},
+		{"Revenue", i.Revenue},
+		{"Cost of Revenue", i.CostOfRevenue},
+		{"Gross Profit", i.GrossProfit},
+		{"Operating Expenses", i.OperatingExpenses},
+		{"Operating Income/Loss", i.OperatingIncomeLoss},
+		{"Other Income/Expense", i.OtherIncomeExpense},
+		{"Income Before Tax", i.IncomeBeforeTax},
+		{"Income Tax", i.IncomeTax},
+		{"Net Income/Loss", i.NetIncomeLoss},
+		{"Operating Activities", c.OperatingActivities},
+		{"Investing Activities", c.InvestingActivities},
+		{"Financing Activities", c.FinancingActivities},
+		{"Cash and Cash Equivalents", c.CashAndCashEquivalents},
+		{"Other Comprehensive Income Items", t.OtherComprehensiveIncomeItems},
+		{"Financial Metrics and Ratios", t.FinancialMetricsAndRatios},
+		{"Share-Based Compensation", t.ShareBasedCompensation},
+		{"Taxes", t.Taxes},
+		{"Leases", t.Leases},
+		{"Debt and Borrowings", t.DebtAndBorrowings},
+		{"Intangible Assets and Goodwill", t.IntangibleAssetsAndGoodwill},
+		{"Commitments and Contingencies", t.CommitmentsAndContingencies},
+		{"Derivatives and Hedging", t.DerivativesAndHedging},
+		{"Stock and Equity-related Items", t.StockAndEquityRelatedItems},
+		{"Other Financial Items", t.OtherFinancialItems},
+	}
+}
+
+// Element names declared in a taxonomy schema
+var elementName = regexp.MustCompile(`<(?:xs:|xsd:)?element[^>]*\sname="([^"]+)"`)
+
+// Reads the concept names of a us-gaap taxonomy: either a schema (.xsd),
+// whose element declarations are read, or a list of one name per line
+func readConcepts(data string) map[string]bool {
+	concepts := make(map[string]bool)
+	if strings.Contains(data, "<") {
+		for _, m := range elementName.FindAllStringSubmatch(data, -1) {
+			concepts[m[1]] = true
+		}
+		return concepts
+	}
+	s := bufio.NewScanner(strings.NewReader(data))
+	for s.Scan() {
+		name := strings.TrimSpace(s.Text())
+		if name != "" && !strings.HasPrefix(name, "#") {
+			concepts[strings.TrimPrefix(name, "us-gaap:")] = true
+		}
+	}
+	return concepts
+}
+
+// Checks every bucket of the mapping against the known concepts. Returns a
+// description of each problem found: unknown concepts, with the closest
+// known concept as a suggestion, duplicates within a bucket and empty
+// buckets.
+func (x *XBRLTags) validate(known map[string]bool) []string {
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0)
+	for _, b := range x.buckets() {
+		if len(b.tags) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no concepts", b.name))
+		}
+		seen := make(map[string]bool)
+		for _, tag := range b.tags {
+			if seen[tag] {
+				problems = append(problems, fmt.Sprintf("%s: %s is listed twice", b.name, tag))
+			}
+			seen[tag] = true
+			if known[tag] {
+				continue
+			}
+			p := fmt.Sprintf("%s: unknown concept %s", b.name, tag)
+			if s := closest(tag, names); s != "" {
+				p += fmt.Sprintf(" (did you mean %s?)", s)
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// Returns the candidate closest to name by edit distance, or "" if none is
+// close enough to be a likely typo
+func closest(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3+1
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Holds the arguments of the mapping subcommand
+type MappingConfig struct {
+	Command  string // validate
+	File     string // mapping overrides to validate, optional
+	Taxonomy string // us-gaap schema or concept list
+}
+
+func (m *MappingConfig) HandleMapping() {
+	switch m.Command {
+	case "validate":
+		m.validate()
+	default:
+		fmt.Println("Error: expected 'validate' mapping subcommand. Exiting...")
+		os.Exit(1)
+	}
+}
+
+// Validates the mapping in effect, default and overrides layered, against
+// the us-gaap taxonomy given with -taxonomy and exits non-zero if it has
+// problems
+func (m *MappingConfig) validate() {
+	if m.Taxonomy == "" {
+		fmt.Println("Error: expected -taxonomy, e.g. -taxonomy us-gaap-2024.xsd from the FASB taxonomy release. Exiting...")
+		os.Exit(1)
+	}
+	xbrl, err := loadXBRLTags(m.File)
+	if err != nil {
+		fmt.Printf("Error: could not load xbrl mapping! (%v)\n", err)
+		os.Exit(1)
+	}
+	b, err := os.ReadFile(m.Taxonomy)
+	if err != nil {
+		fmt.Printf("Error: could not read taxonomy! (%v)\n", err)
+		os.Exit(1)
+	}
+	known := readConcepts(string(b))
+	if len(known) == 0 {
+		fmt.Printf("Error: no concepts found in %s!\n", m.Taxonomy)
+		os.Exit(1)
+	}
+	problems := xbrl.validate(known)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	count := 0
+	for _, b := range xbrl.buckets() {
+		count += len(b.tags)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Mapping %s: %d problem(s) in %d concept(s)\n", xbrl.Version, len(problems), count)
+		os.Exit(1)
+	}
+	fmt.Printf("Mapping %s: %d concept(s) OK\n", xbrl.Version, count)
+}
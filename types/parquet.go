@@ -0,0 +1,308 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// A FactRecord is one reported fact as written to Parquet. Dates are DATE
+// columns; optional columns are null where a fact has no value for them.
+type FactRecord struct {
+	CIK                int64    `parquet:"cik"`
+	Entity             string   `parquet:"entity,dict"`
+	Taxonomy           string   `parquet:"taxonomy,dict"`          // e.g. us-gaap, dei or ifrs-full
+	Concept            string   `parquet:"concept,dict"`           // e.g. Revenues
+	Label              string   `parquet:"label,dict"`             // label of the concept
+	Unit               string   `parquet:"unit,dict"`              // e.g. USD, shares, USD/shares or pure
+	Start              int32    `parquet:"start,date,optional"`    // null for instant facts
+	End                int32    `parquet:"end,date"`               // period end, or the instant
+	Value              *float64 `parquet:"value,optional"`         // the value as a double, null if not numeric
+	ValueText          string   `parquet:"value_text,optional"`    // the exact value as reported
+	FiscalYear         int32    `parquet:"fy,optional"`            // fiscal year of the filing
+	ForPeriod          string   `parquet:"fp,dict,optional"`       // fiscal period of the filing: FY, Q1-Q3
+	Form               string   `parquet:"form,dict"`              // form of the filing, e.g. 10-K or 10-K/A
+	Filed              int32    `parquet:"filed,date"`             // filing date
+	Accession          string   `parquet:"accession"`              // accession number of the filing
+	Frame              string   `parquet:"frame,dict,optional"`    // SEC calendar frame, e.g. CY2023Q2I
+	PeriodFiscalYear   int32    `parquet:"fiscal_year"`            // fiscal year of the fact's own period
+	PeriodFiscalPeriod string   `parquet:"fiscal_period,dict"`     // FY, Q1-Q4, or year-to-date months, e.g. 9M
+	Calendar           string   `parquet:"calendar,dict,optional"` // calendar period, see FactPeriod
+	FiscalYearEnd      string   `parquet:"fiscal_year_end,dict"`   // MMDD
+}
+
+// A FilingRecord is one filing from a company's submissions
+type FilingRecord struct {
+	CIK             int64  `parquet:"cik"`
+	Entity          string `parquet:"entity,dict"`
+	Accession       string `parquet:"accession"`
+	Form            string `parquet:"form,dict"`
+	FilingDate      int32  `parquet:"filing_date,date"`
+	ReportDate      int32  `parquet:"report_date,date,optional"` // end of the period reported on
+	PrimaryDocument string `parquet:"primary_document"`
+	URL             string `parquet:"url"`
+	XBRL            bool   `parquet:"xbrl"`
+	InlineXBRL      bool   `parquet:"inline_xbrl"`
+}
+
+// Returns a YYYY-MM-DD date as days since the Unix epoch, the encoding of a
+// Parquet DATE, or 0 (null in optional columns) if it is not a date
+func parquetDate(date string) int32 {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+	return int32(t.Unix() / 86400)
+}
+
+// Returns the facts of a company as records, keyed by the partition they
+// are written to: the taxonomy, or the fiscal year of the fact's period
+func factRecords(f *CompanyFacts, fc fiscalCalendar, partition string) map[string][]FactRecord {
+	parts := make(map[string][]FactRecord)
+	for taxonomy, data := range f.taxonomies() {
+		for concept, fact := range data {
+			for unit, entries := range fact.Units.byUnit() {
+				for i := range entries {
+					e := &entries[i]
+					p := fc.align(e)
+					var value *float64
+					if v, err := e.Value.Float64(); err == nil {
+						value = &v
+					}
+					rec := FactRecord{
+						CIK:                int64(f.Cik),
+						Entity:             f.EntityName,
+						Taxonomy:           taxonomy,
+						Concept:            concept,
+						Label:              fact.Label,
+						Unit:               unit,
+						Start:              parquetDate(e.PeriodStart),
+						End:                parquetDate(e.PeriodEnd),
+						Value:              value,
+						ValueText:          e.Value.String(),
+						FiscalYear:         int32(e.FiscalYear),
+						ForPeriod:          e.ForPeriod,
+						Form:               e.Form,
+						Filed:              parquetDate(e.Filed),
+						Accession:          e.Accession,
+						Frame:              e.Frame,
+						PeriodFiscalYear:   int32(p.FiscalYear),
+						PeriodFiscalPeriod: p.FiscalPeriod,
+						Calendar:           p.Calendar,
+						FiscalYearEnd:      fc.String(),
+					}
+					key := "taxonomy=" + taxonomy
+					if partition == "fy" {
+						key = "fiscal_year=" + strconv.Itoa(p.FiscalYear)
+					}
+					parts[key] = append(parts[key], rec)
+				}
+			}
+		}
+	}
+	// Sorted rows compress better and make reruns byte for byte stable
+	for _, recs := range parts {
+		sort.Slice(recs, func(i, j int) bool {
+			a, b := &recs[i], &recs[j]
+			switch {
+			case a.Taxonomy != b.Taxonomy:
+				return a.Taxonomy < b.Taxonomy
+			case a.Concept != b.Concept:
+				return a.Concept < b.Concept
+			case a.Unit != b.Unit:
+				return a.Unit < b.Unit
+			case a.End != b.End:
+				return a.End < b.End
+			case a.Start != b.Start:
+				return a.Start < b.Start
+			case a.Filed != b.Filed:
+				return a.Filed < b.Filed
+			}
+			return a.Accession < b.Accession
+		})
+	}
+	return parts
+}
+
+// Returns the recent filings of a company's submissions as records
+func filingRecords(cf *CompanyFilings) []FilingRecord {
+	cik, _ := strconv.ParseInt(strings.TrimLeft(cf.Cik, "0"), 10, 64)
+	recent := cf.Filings.Recent
+	at := func(s []string, i int) string {
+		if i < len(s) {
+			return s[i]
+		}
+		return ""
+	}
+	flag := func(s []int, i int) bool {
+		return i < len(s) && s[i] == 1
+	}
+	recs := make([]FilingRecord, 0, len(recent.AccessionNumber))
+	for i, accession := range recent.AccessionNumber {
+		recs = append(recs, FilingRecord{
+			CIK:             cik,
+			Entity:          cf.Name,
+			Accession:       accession,
+			Form:            at(recent.Form, i),
+			FilingDate:      parquetDate(at(recent.FilingDate, i)),
+			ReportDate:      parquetDate(at(recent.ReportDate, i)),
+			PrimaryDocument: at(recent.PrimaryDocument, i),
+			URL:             filingUrl(cf.Cik, accession, at(recent.PrimaryDocument, i)),
+			XBRL:            flag(recent.IsXBRL, i),
+			InlineXBRL:      flag(recent.IsInlineXBRL, i),
+		})
+	}
+	return recs
+}
+
+// Holds the arguments of the parquet subcommand
+type ParquetConfig struct {
+	GetConfig
+	Tickers   []string // positional tickers
+	Watchlist string   // file of tickers, one per line
+	All       bool     // every company in the store
+	Dir       string   // output directory
+	Partition string   // taxonomy or fy
+	Filings   bool     // also export filing metadata from the submissions
+	Schema    bool     // print the schemas and exit
+}
+
+// Returns the padded CIKs to export: -cik, the tickers and watchlist, and
+// with -all every company in the store
+func (pc *ParquetConfig) companies(c *ClientConfig) ([]string, error) {
+	ciks := make([]string, 0)
+	if pc.CIK != "" {
+		ciks = append(ciks, pc.CIK)
+	}
+	tickers := append([]string{}, pc.Tickers...)
+	if pc.Watchlist != "" {
+		listed, err := readWatchlist(pc.Watchlist)
+		if err != nil {
+			return nil, err
+		}
+		tickers = append(tickers, listed...)
+	}
+	if len(tickers) > 0 {
+		known := c.checkCompanyTickers()
+		for _, t := range tickers {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			cik, ok := known[t]
+			if !ok {
+				return nil, fmt.Errorf("ticker %s not found", t)
+			}
+			ciks = append(ciks, zeroPad(strconv.Itoa(cik)))
+		}
+	}
+	if pc.All {
+		if pc.Store == "" {
+			return nil, fmt.Errorf("-all exports the companies of a store, expected -store")
+		}
+		s, err := OpenStore(pc.Store)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		stored, err := s.Companies()
+		if err != nil {
+			return nil, err
+		}
+		for _, cik := range stored {
+			ciks = append(ciks, zeroPad(strconv.Itoa(cik)))
+		}
+	}
+	return ciks, nil
+}
+
+// Writes the facts of one company, and its filings with -filings, under
+// pc.Dir. Each company gets its own file in every partition, named after
+// its CIK, so reruns and bulk exports replace rather than duplicate rows.
+// Returns the number of facts written.
+func (pc *ParquetConfig) export(c *ClientConfig, g *GetConfig) (int, error) {
+	facts, err := g.companyFacts(c, assembleUrl(g.CIK, companyFacts))
+	if err != nil {
+		return 0, err
+	}
+	var filings *CompanyFilings
+	fc := inferFiscalYearEnd(facts)
+	if pc.Filings || pc.Store == "" || pc.Sync {
+		filings, err = c.getCompanyFilings(g.CIK)
+		if err != nil && pc.Filings {
+			return 0, err
+		}
+		if err == nil {
+			if known, err := parseFiscalYearEnd(filings.FiscalYearEnd); err == nil {
+				fc = known
+			}
+		}
+	}
+	name := g.CIK + ".parquet"
+	written := 0
+	for part, recs := range factRecords(facts, fc, pc.Partition) {
+		dir := filepath.Join(pc.Dir, "facts", part)
+		err := createDir(dir)
+		if err != nil {
+			return written, err
+		}
+		err = parquet.WriteFile(filepath.Join(dir, name), recs, parquet.Compression(&parquet.Zstd))
+		if err != nil {
+			return written, err
+		}
+		written += len(recs)
+	}
+	if pc.Filings {
+		dir := filepath.Join(pc.Dir, "filings")
+		err := createDir(dir)
+		if err != nil {
+			return written, err
+		}
+		err = parquet.WriteFile(filepath.Join(dir, name), filingRecords(filings), parquet.Compression(&parquet.Zstd))
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (pc *ParquetConfig) HandleParquet() {
+	if pc.Schema {
+		fmt.Println(parquet.SchemaOf(FactRecord{}))
+		fmt.Println(parquet.SchemaOf(FilingRecord{}))
+		return
+	}
+	if pc.Partition != "taxonomy" && pc.Partition != "fy" {
+		fmt.Printf("Error: unknown partition %q, expected taxonomy or fy. Exiting...\n", pc.Partition)
+		os.Exit(1)
+	}
+	c := checkConfig()
+	ciks, err := pc.companies(c)
+	if err != nil {
+		fmt.Printf("Error: could not determine the companies to export! (%v)\n", err)
+		os.Exit(1)
+	}
+	if len(ciks) == 0 {
+		fmt.Println("Error: expected tickers, -cik, -watchlist or -all, e.g. edgar parquet AAPL MSFT. Exiting...")
+		os.Exit(1)
+	}
+	failed := 0
+	for _, cik := range ciks {
+		g := pc.GetConfig
+		g.CIK = cik
+		n, err := pc.export(c, &g)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not export %s! (%v)\n", cik, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d facts of %s to %s\n", n, cik, pc.Dir)
+	}
+	if failed > 0 {
+		fmt.Printf("Error: %d of %d companies failed to export!\n", failed, len(ciks))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,364 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Holds the options for the parse subcommand
+type ParseConfig struct {
+	File     string // downloaded filing HTML, e.g. app/AAPL/aapl-20230930.htm
+	Format   string // text or markdown; json or csv with Tables
+	Sections string // comma separated items to extract as JSON, e.g. 1A,7
+	Tables   bool   // extract financial tables instead of text
+	Inline   bool   // extract inline XBRL facts instead of text
+}
+
+// Elements that start a new line when rendered as text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "body": true,
+	"center": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"tr": true, "ul": true,
+}
+
+// Elements whose content is never part of the readable filing
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true,
+	"ix:header": true, // inline XBRL contexts, units and hidden facts
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\r\n\x{00a0}\x{200b}]+`)
+	lineSpaces = regexp.MustCompile(`[ \x{00a0}\x{200b}]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+	// Standalone page numbers such as "12", "- 12 -", "F-3" or "ii"
+	pageNumber = regexp.MustCompile(`(?i)^[-–—\s]*(?:[a-z]-)?(?:\d{1,3}|[ivxlc]{1,6})[-–—\s]*$`)
+)
+
+// Marks a page break in the raw text, removed again by normalizeText
+const pageBreak = "\f"
+
+// Parses a filing document into an HTML node tree
+func parseFilingHTML(r io.Reader) (*html.Node, error) {
+	return html.Parse(r)
+}
+
+// Returns the value of the named attribute, or "" if it is not set
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// Returns the element's inline style, lower-cased with whitespace removed
+func style(n *html.Node) string {
+	return strings.ToLower(strings.Join(strings.Fields(attr(n, "style")), ""))
+}
+
+// Reports whether the element is hidden, as the inline XBRL header is
+func hidden(n *html.Node) bool {
+	return n.Type == html.ElementNode && (skippedElements[n.Data] || strings.Contains(style(n), "display:none"))
+}
+
+// Reports whether the element forces a page break before or after itself
+func breaksPage(n *html.Node) bool {
+	s := style(n)
+	return strings.Contains(s, "page-break-before:always") ||
+		strings.Contains(s, "page-break-after:always") ||
+		strings.Contains(s, "break-before:page") ||
+		strings.Contains(s, "break-after:page")
+}
+
+// Reports whether every bit of text in the element is bold
+func bold(n *html.Node) bool {
+	if n.Type == html.TextNode {
+		return strings.TrimSpace(n.Data) == ""
+	}
+	if n.Type == html.ElementNode {
+		if n.Data == "b" || n.Data == "strong" {
+			return true
+		}
+		s := style(n)
+		if strings.Contains(s, "font-weight:bold") || strings.Contains(s, "font-weight:700") {
+			return true
+		}
+	}
+	hasText := false
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" {
+			continue
+		}
+		if !bold(c) {
+			return false
+		}
+		hasText = true
+	}
+	return hasText
+}
+
+// Renders the text of a node tree. Block elements start new lines, tables
+// are rendered one row per line and hidden content is dropped. When markdown
+// is set, headings, bold paragraphs, list items and tables are marked up.
+type textRenderer struct {
+	b        strings.Builder
+	markdown bool
+}
+
+func (t *textRenderer) newline() {
+	s := t.b.String()
+	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+		t.b.WriteString("\n")
+	}
+}
+
+func (t *textRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		t.b.WriteString(spaceRun.ReplaceAllString(n.Data, " "))
+		return
+	case html.ElementNode:
+		if hidden(n) {
+			return
+		}
+		switch n.Data {
+		case "br":
+			t.b.WriteString("\n")
+			return
+		case "table":
+			t.newline()
+			t.table(n)
+			t.newline()
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	if block {
+		t.newline()
+		if breaksPage(n) && strings.Contains(style(n), "before") {
+			t.b.WriteString(pageBreak + "\n")
+		}
+		if t.markdown {
+			t.b.WriteString(markdownPrefix(n))
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		t.render(c)
+	}
+	if block {
+		t.newline()
+		if breaksPage(n) && !strings.Contains(style(n), "before") {
+			t.b.WriteString(pageBreak + "\n")
+		}
+	}
+}
+
+// Returns the markdown marker that starts the rendered block
+func markdownPrefix(n *html.Node) string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return strings.Repeat("#", int(n.Data[1]-'0')) + " "
+	case "li":
+		return "- "
+	case "p", "div":
+		if bold(n) {
+			// Whole-bold paragraphs are the headings of most filings
+			return "### "
+		}
+	}
+	return ""
+}
+
+// Renders each table row on its own line with the cells separated
+func (t *textRenderer) table(n *html.Node) {
+	rows := tableRows(n)
+	for i, row := range rows {
+		if t.markdown {
+			t.b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+			if i == 0 {
+				t.b.WriteString(strings.Repeat("| --- ", len(row)) + "|\n")
+			}
+		} else {
+			t.b.WriteString(strings.Join(row, "\t") + "\n")
+		}
+	}
+}
+
+// Returns the non-empty rows of a table as text cells. Filings split "$",
+// ")" and "%" into cells of their own; those are merged with their number.
+func tableRows(table *html.Node) [][]string {
+	rows := make([][]string, 0)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if hidden(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			row := mergeCells(rowCells(n))
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(table)
+	return rows
+}
+
+// Returns the rendered text of each td/th in a table row
+func rowCells(tr *html.Node) []string {
+	cells := make([]string, 0)
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") || hidden(c) {
+			continue
+		}
+		cells = append(cells, nodeText(c))
+	}
+	return cells
+}
+
+// Drops empty cells and glues currency symbols, closing parentheses and
+// percent signs onto the number they belong to
+func mergeCells(cells []string) []string {
+	merged := make([]string, 0, len(cells))
+	pending := ""
+	for _, c := range cells {
+		switch {
+		case c == "":
+			continue
+		case c == "$" || c == "(" || c == "$(" || c == "($":
+			pending += c
+			continue
+		case (c == ")" || c == "%" || c == ")%" || c == "%)") && len(merged) > 0:
+			merged[len(merged)-1] += c
+			continue
+		}
+		merged = append(merged, pending+c)
+		pending = ""
+	}
+	return merged
+}
+
+// Returns the whitespace-normalized text content of a node
+func nodeText(n *html.Node) string {
+	t := &textRenderer{}
+	t.render(n)
+	return strings.TrimSpace(spaceRun.ReplaceAllString(t.b.String(), " "))
+}
+
+// Trims every line, collapses repeated spaces and blank lines and removes
+// page breaks together with the page numbers and "Table of Contents" links
+// around them. Tabs separating table cells are kept.
+func normalizeText(s string) string {
+	in := strings.Split(s, "\n")
+	out := make([]string, 0, len(in))
+	afterBreak := false
+	for _, line := range in {
+		if line == pageBreak {
+			for len(out) > 0 {
+				last := out[len(out)-1]
+				if last == "" || pageNumber.MatchString(last) || strings.EqualFold(last, "table of contents") {
+					out = out[:len(out)-1]
+					continue
+				}
+				break
+			}
+			out = append(out, "")
+			afterBreak = true
+			continue
+		}
+		line = strings.Trim(lineSpaces.ReplaceAllString(line, " "), " \t\r")
+		if afterBreak {
+			if line == "" || strings.EqualFold(line, "table of contents") {
+				continue
+			}
+			afterBreak = false
+		}
+		out = append(out, line)
+	}
+	text := strings.Join(out, "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
+
+// Converts a filing document into clean text, or markdown if requested
+func FilingText(r io.Reader, markdown bool) (string, error) {
+	doc, err := parseFilingHTML(r)
+	if err != nil {
+		return "", err
+	}
+	return renderText(doc, markdown), nil
+}
+
+func renderText(doc *html.Node, markdown bool) string {
+	t := &textRenderer{markdown: markdown}
+	t.render(doc)
+	return normalizeText(t.b.String())
+}
+
+// Returns the source file without its extension. Parsed output is written
+// next to the source with a new extension.
+func (p *ParseConfig) outputBase() string {
+	return strings.TrimSuffix(p.File, filepath.Ext(p.File))
+}
+
+func (p *ParseConfig) markdown() bool {
+	return p.Format == "markdown" || p.Format == "md"
+}
+
+func (p *ParseConfig) HandleParse() {
+	if p.File == "" {
+		fmt.Println("Error: expected a file to parse, e.g. edgar parse app/AAPL/aapl-20230930.htm. Exiting...")
+		os.Exit(1)
+	}
+	f, err := os.Open(p.File)
+	if err != nil {
+		fmt.Printf("Error: could not open %s! (%v)\n", p.File, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	doc, err := parseFilingHTML(f)
+	if err != nil {
+		fmt.Printf("Error: could not parse %s! (%v)\n", p.File, err)
+		os.Exit(1)
+	}
+	if p.Tables {
+		p.writeTables(doc)
+		return
+	}
+	if p.Inline {
+		p.writeInlineFacts(doc)
+		return
+	}
+	// Sections are always cut from plain text so headings are not marked up
+	text := renderText(doc, p.markdown() && p.Sections == "")
+	if p.Sections != "" {
+		p.writeSections(text)
+		return
+	}
+	out := p.outputBase() + ".txt"
+	if p.markdown() {
+		out = p.outputBase() + ".md"
+	}
+	err = os.WriteFile(out, []byte(text), 0666)
+	if err != nil {
+		fmt.Printf("Error: could not write file! (%v)\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Parsed %s to %s\n", p.File, out)
+}
@@ -0,0 +1,284 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Durations, in days, of the periods a filing reports. 52/53-week fiscal
+// years run 364 or 371 days and 13/14-week quarters 91 or 98 days.
+const (
+	minYearDays    = 350
+	maxYearDays    = 380
+	minQuarterDays = 80
+	maxQuarterDays = 100
+)
+
+// A Period is one column of a report: the fiscal period a filing covers
+type Period struct {
+	Label      string `json:"label"` // e.g. "FY 2023" or "Q2 2024"
+	FiscalYear int    `json:"fy"`
+	ForPeriod  string `json:"fp"`
+	Form       string `json:"form"`
+	End        string `json:"end"`
+	Calendar   string `json:"calendar"` // calendar year or quarter the period aligns to, e.g. "CY2023Q2"
+}
+
+// Returns the calendar year ("CY2023") or quarter ("CY2023Q2") a fiscal
+// period ending on end aligns to: the one containing the middle of the
+// period. Apple's fiscal year ending in September 2023 aligns to CY2023;
+// Walmart's ending in January 2024 does too.
+func calendarPeriod(end string, annual bool) string {
+	t, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return ""
+	}
+	if annual {
+		return fmt.Sprintf("CY%d", t.AddDate(0, -6, 0).Year())
+	}
+	mid := t.AddDate(0, 0, -45)
+	return fmt.Sprintf("CY%dQ%d", mid.Year(), (int(mid.Month())+2)/3)
+}
+
+// Returns the number of days covered by a duration fact, or 0 for an instant
+func durationDays(e *UnitEntry) int {
+	if e.PeriodStart == "" {
+		return 0
+	}
+	start, err := time.Parse("2006-01-02", e.PeriodStart)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse("2006-01-02", e.PeriodEnd)
+	if err != nil {
+		return 0
+	}
+	return int(end.Sub(start).Hours()/24) + 1
+}
+
+// Reports whether a fact covers the period a filing for fiscal period fp
+// reports on: a full year for FY, a single quarter for Q1-Q4. Instant facts,
+// such as balance sheet items, always match.
+func coversFiscalPeriod(e *UnitEntry, fp string) bool {
+	if e.PeriodStart == "" {
+		return true
+	}
+	days := durationDays(e)
+	if fp == "FY" {
+		return days >= minYearDays && days <= maxYearDays
+	}
+	return days >= minQuarterDays && days <= maxQuarterDays
+}
+
+// Reports whether a form is the given form or an amendment of it
+func sameForm(form, doc string) bool {
+	return form == doc || form == doc+"/A"
+}
+
+// Returns the fiscal years requested with -years ("2019-2024" or "2023"),
+// or the single -period year
+func (g *GetConfig) fiscalYears() ([]int, error) {
+	if g.Years == "" {
+		return []int{g.Period}, nil
+	}
+	from, to, found := strings.Cut(g.Years, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return nil, fmt.Errorf("invalid years %q", g.Years)
+	}
+	last := first
+	if found {
+		last, err = strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || last < first {
+			return nil, fmt.Errorf("invalid years %q", g.Years)
+		}
+	}
+	years := make([]int, 0, last-first+1)
+	for y := first; y <= last; y++ {
+		years = append(years, y)
+	}
+	return years, nil
+}
+
+// Determines the columns of the report. With -calendar this is the fiscal
+// period aligned to the calendar year or quarter; with -quarters the latest
+// quarters reported in quarterly filings; otherwise every fiscal period
+// reported in the -doc forms for the requested fiscal years. Quarterly
+// reports gain a Q4 column for each annual report, derived from its fiscal
+// year. A filing repeats earlier periods as comparatives under its own fy,
+// so each period ends on the period end most often reported for its fy and
+// fp (see mostCommonEnd).
+func (g *GetConfig) reportPeriods(f *CompanyFacts) ([]Period, error) {
+	forms, err := formSet(g.Doc)
+	if err != nil {
+		return nil, err
+	}
+	var wanted func(fy int, fp string) bool
+	var calendar string
+	if g.Calendar != "" {
+		year, quarter, err := parseCalendar(g.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		calendar = calendarLabel(year, quarter)
+		fc := g.fiscal
+		if fc.month == 0 {
+			fc = inferFiscalYearEnd(f)
+		}
+		forms, _ = formSet("annual")
+		if quarter != 0 {
+			forms, _ = formSet("quarterly")
+		}
+		// Some companies name a fiscal year after the calendar year it
+		// starts in, so the year before is a candidate too; the calendar
+		// alignment of its period end settles it
+		fy, fp := fc.fiscalFor(year, quarter)
+		wanted = func(y int, p string) bool { return (y == fy || y == fy-1) && p == fp }
+	} else if g.Quarters > 0 {
+		forms, _ = formSet("quarterly")
+		wanted = func(fy int, fp string) bool { return fp != "FY" }
+	} else {
+		years, err := g.fiscalYears()
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[int]bool)
+		for _, y := range years {
+			set[y] = true
+		}
+		wanted = func(fy int, fp string) bool { return set[fy] }
+	}
+
+	quarterly := false
+	for form := range forms {
+		quarterly = quarterly || inFormGroup(form, "quarterly")
+	}
+
+	// A filing also reports the comparative periods before its own, so the
+	// period ends of facts spanning the fiscal period are counted and the
+	// most common one, the latest among equals, ends the column. Filings
+	// tagging no such duration fall back to their latest period end.
+	found := make(map[string]*Period)
+	ends := make(map[string]map[string]int)
+	add := func(v *UnitEntry, fp string) {
+		key := fmt.Sprint(v.FiscalYear, fp)
+		p, ok := found[key]
+		if !ok {
+			p = &Period{
+				Label:      fmt.Sprintf("%s %d", fp, v.FiscalYear),
+				FiscalYear: v.FiscalYear,
+				ForPeriod:  fp,
+				Form:       v.Form,
+			}
+			found[key] = p
+			ends[key] = make(map[string]int)
+		}
+		if v.PeriodEnd > p.End {
+			p.End = v.PeriodEnd
+		}
+		if v.PeriodStart != "" && coversFiscalPeriod(v, v.ForPeriod) {
+			ends[key][v.PeriodEnd]++
+		}
+	}
+	for _, fact := range f.Facts.Data {
+		for i := range fact.Units.USD {
+			v := &fact.Units.USD[i]
+			switch {
+			case forms[v.Form] && wanted(v.FiscalYear, v.ForPeriod):
+				add(v, v.ForPeriod)
+			// The fourth quarter has no 10-Q; it ends with the fiscal year
+			case quarterly && inFormGroup(v.Form, "annual") && v.ForPeriod == "FY" && wanted(v.FiscalYear, "Q4"):
+				add(v, "Q4")
+			}
+		}
+	}
+	periods := make([]Period, 0, len(found))
+	for key, p := range found {
+		if end := mostCommonEnd(ends[key]); end != "" {
+			p.End = end
+		}
+		p.Calendar = calendarPeriod(p.End, p.ForPeriod == "FY")
+		if calendar != "" && p.Calendar != calendar {
+			continue
+		}
+		periods = append(periods, *p)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].End < periods[j].End
+	})
+	if g.Quarters > 0 && len(periods) > g.Quarters {
+		periods = periods[len(periods)-g.Quarters:]
+	}
+	return periods, nil
+}
+
+// Returns the period end counted most often, the latest among equals
+func mostCommonEnd(counts map[string]int) string {
+	best := ""
+	for end, n := range counts {
+		if n > counts[best] || (n == counts[best] && end > best) {
+			best = end
+		}
+	}
+	return best
+}
+
+// Selects the value of each column of the report, nil where the concept was
+// not reported. Of the facts ending on the column's period end, the one
+// covering the fiscal period is kept: a 12 month duration in annual and a 3
+// month duration in quarterly columns. Quarters of additive (USD) concepts
+// only reported year-to-date are derived by differencing (see
+// discreteQuarter) and left nil when that is not possible, so a year-to-date
+// or annual value never stands in for the quarter. Other concepts fall back
+// to the longest duration. When a later filing restated the period, the latest filed value
+// wins and the value first reported is kept as its Original.
+func (g *GetConfig) findRelevantUnitEntries(entries *[]UnitEntry, additive bool) []*ReportValue {
+	relevantEntries := make([]*ReportValue, len(g.periods))
+	for col, p := range g.periods {
+		var best *UnitEntry
+		bestCovers := false
+		for i := range *entries {
+			v := &(*entries)[i]
+			if v.PeriodEnd != p.End || !sameForm(v.Form, p.Form) {
+				continue
+			}
+			covers := coversFiscalPeriod(v, p.ForPeriod)
+			switch {
+			case best == nil,
+				covers && !bestCovers,
+				covers == bestCovers && !covers && durationDays(v) > durationDays(best),
+				covers == bestCovers && durationDays(v) == durationDays(best) && v.Filed > best.Filed:
+				best, bestCovers = v, covers
+			}
+		}
+		if (best == nil || !bestCovers) && p.ForPeriod != "FY" && additive {
+			relevantEntries[col] = discreteQuarter(*entries, p)
+			continue
+		}
+		if best != nil {
+			rv := &ReportValue{UnitEntry: *best}
+			first := firstReported(*entries, best)
+			if _, changed := valueChange(first.Value, best.Value); changed {
+				rv.Restated, rv.Original = true, first.Value
+			}
+			relevantEntries[col] = rv
+		}
+	}
+	return relevantEntries
+}
+
+// Describes the requested period for the summary printed after a report
+func (g *GetConfig) periodLabel() string {
+	switch {
+	case g.Calendar != "":
+		return g.Calendar
+	case g.Quarters > 0:
+		return fmt.Sprintf("last %d quarters", g.Quarters)
+	case g.Years != "":
+		return g.Years
+	}
+	return strconv.Itoa(g.Period)
+}
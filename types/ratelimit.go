@@ -0,0 +1,35 @@
+package types
+
+import (
+	"sync"
+	"time"
+)
+
+// The SEC allows at most 10 requests per second from a single client. Every
+// request made through makeSecRequest waits on this limiter first.
+var secLimiter = newRateLimiter(10)
+
+// A rateLimiter spaces calls to wait so that no more than perSecond of them
+// return in any one second
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+// Blocks until the next request slot is available
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(slot))
+}
@@ -0,0 +1,330 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Ratio is one financial ratio computed for every column of a report
+type Ratio struct {
+	Name     string       `json:"name"`
+	Category string       `json:"category"`
+	Formula  string       `json:"formula"`
+	Values   []RatioValue `json:"values"`
+}
+
+// The value of a ratio in one period. When a required input was not
+// reported, Value is nil and Missing names the inputs.
+type RatioValue struct {
+	Period  string   `json:"period"`
+	Value   *float64 `json:"value"`
+	Missing []string `json:"missing,omitempty"`      // required inputs not reported
+	Assumed []string `json:"assumed_zero,omitempty"` // optional inputs not reported, taken as zero
+	Note    string   `json:"note,omitempty"`         // why a value with all its inputs is undefined
+}
+
+// The inputs of a ratio in one period: standardized line values by name,
+// and the length of the period in days
+type ratioInputs struct {
+	v    map[string]float64
+	days float64
+}
+
+// Divides a by b, failing on division by zero
+func (in ratioInputs) div(a, b float64, name string) (float64, string) {
+	if b == 0 {
+		return 0, name + " is zero"
+	}
+	return a / b, ""
+}
+
+// A ratio over the standardized lines. Optional inputs are taken as zero
+// when not reported, e.g. the debt of a company without debt.
+type ratioDef struct {
+	name, category, formula string
+	required, optional      []string
+	compute                 func(in ratioInputs) (float64, string)
+}
+
+func (in ratioInputs) debt() float64 {
+	return in.v["ShortTermDebt"] + in.v["LongTermDebt"]
+}
+
+func (in ratioInputs) fcf() float64 {
+	return in.v["OperatingCashFlow"] - in.v["CapitalExpenditures"]
+}
+
+func (in ratioInputs) ebitda() float64 {
+	return in.v["EBIT"] + in.v["DepreciationAndAmortization"]
+}
+
+// Returns a ratio that divides one standardized line by another
+func simpleRatio(name, category, num, den string) ratioDef {
+	return ratioDef{name, category, num + " / " + den, []string{num, den}, nil,
+		func(in ratioInputs) (float64, string) { return in.div(in.v[num], in.v[den], den) }}
+}
+
+var debtLines = []string{"ShortTermDebt", "LongTermDebt"}
+
+var ratioDefs = []ratioDef{
+	simpleRatio("Gross Margin", "margins", "GrossProfit", "TotalRevenue"),
+	simpleRatio("Operating Margin", "margins", "EBIT", "TotalRevenue"),
+	simpleRatio("Net Margin", "margins", "NetIncome", "TotalRevenue"),
+	{"EBITDA Margin", "margins", "(EBIT + DepreciationAndAmortization) / TotalRevenue",
+		[]string{"EBIT", "DepreciationAndAmortization", "TotalRevenue"}, nil,
+		func(in ratioInputs) (float64, string) {
+			return in.div(in.ebitda(), in.v["TotalRevenue"], "TotalRevenue")
+		}},
+	{"FCF Margin", "margins", "(OperatingCashFlow - CapitalExpenditures) / TotalRevenue",
+		[]string{"OperatingCashFlow", "TotalRevenue"}, []string{"CapitalExpenditures"},
+		func(in ratioInputs) (float64, string) { return in.div(in.fcf(), in.v["TotalRevenue"], "TotalRevenue") }},
+
+	simpleRatio("ROE", "returns", "NetIncome", "TotalEquity"),
+	simpleRatio("ROA", "returns", "NetIncome", "TotalAssets"),
+	{"ROIC", "returns", "EBIT * (1 - IncomeTax / PretaxIncome) / (TotalEquity + ShortTermDebt + LongTermDebt - CashAndEquivalents)",
+		[]string{"EBIT", "IncomeTax", "PretaxIncome", "TotalEquity", "CashAndEquivalents"}, debtLines,
+		func(in ratioInputs) (float64, string) {
+			rate, note := in.div(in.v["IncomeTax"], in.v["PretaxIncome"], "PretaxIncome")
+			if note != "" {
+				return 0, note
+			}
+			invested := in.v["TotalEquity"] + in.debt() - in.v["CashAndEquivalents"]
+			return in.div(in.v["EBIT"]*(1-rate), invested, "invested capital")
+		}},
+
+	simpleRatio("Current Ratio", "liquidity", "TotalCurrentAssets", "TotalCurrentLiabilities"),
+	{"Quick Ratio", "liquidity", "(CashAndEquivalents + ShortTermInvestments + AccountsReceivable) / TotalCurrentLiabilities",
+		[]string{"CashAndEquivalents", "TotalCurrentLiabilities"}, []string{"ShortTermInvestments", "AccountsReceivable"},
+		func(in ratioInputs) (float64, string) {
+			quick := in.v["CashAndEquivalents"] + in.v["ShortTermInvestments"] + in.v["AccountsReceivable"]
+			return in.div(quick, in.v["TotalCurrentLiabilities"], "TotalCurrentLiabilities")
+		}},
+	{"Cash Ratio", "liquidity", "(CashAndEquivalents + ShortTermInvestments) / TotalCurrentLiabilities",
+		[]string{"CashAndEquivalents", "TotalCurrentLiabilities"}, []string{"ShortTermInvestments"},
+		func(in ratioInputs) (float64, string) {
+			cash := in.v["CashAndEquivalents"] + in.v["ShortTermInvestments"]
+			return in.div(cash, in.v["TotalCurrentLiabilities"], "TotalCurrentLiabilities")
+		}},
+
+	{"Debt to Equity", "leverage", "(ShortTermDebt + LongTermDebt) / TotalEquity",
+		[]string{"TotalEquity"}, debtLines,
+		func(in ratioInputs) (float64, string) { return in.div(in.debt(), in.v["TotalEquity"], "TotalEquity") }},
+	{"Debt to Assets", "leverage", "(ShortTermDebt + LongTermDebt) / TotalAssets",
+		[]string{"TotalAssets"}, debtLines,
+		func(in ratioInputs) (float64, string) { return in.div(in.debt(), in.v["TotalAssets"], "TotalAssets") }},
+	simpleRatio("Liabilities to Equity", "leverage", "TotalLiabilities", "TotalEquity"),
+	simpleRatio("Interest Coverage", "leverage", "EBIT", "InterestExpense"),
+	{"Net Debt to EBITDA", "leverage", "(ShortTermDebt + LongTermDebt - CashAndEquivalents) / (EBIT + DepreciationAndAmortization)",
+		[]string{"CashAndEquivalents", "EBIT", "DepreciationAndAmortization"}, debtLines,
+		func(in ratioInputs) (float64, string) {
+			return in.div(in.debt()-in.v["CashAndEquivalents"], in.ebitda(), "EBITDA")
+		}},
+
+	simpleRatio("Asset Turnover", "turnover", "TotalRevenue", "TotalAssets"),
+	simpleRatio("Inventory Turnover", "turnover", "COGS", "Inventory"),
+	simpleRatio("Receivables Turnover", "turnover", "TotalRevenue", "AccountsReceivable"),
+	{"Days Sales Outstanding", "turnover", "AccountsReceivable / TotalRevenue * days",
+		[]string{"AccountsReceivable", "TotalRevenue"}, nil,
+		func(in ratioInputs) (float64, string) {
+			r, note := in.div(in.v["AccountsReceivable"], in.v["TotalRevenue"], "TotalRevenue")
+			return r * in.days, note
+		}},
+	{"Days Inventory Outstanding", "turnover", "Inventory / COGS * days",
+		[]string{"Inventory", "COGS"}, nil,
+		func(in ratioInputs) (float64, string) {
+			r, note := in.div(in.v["Inventory"], in.v["COGS"], "COGS")
+			return r * in.days, note
+		}},
+	{"Days Payables Outstanding", "turnover", "AccountsPayable / COGS * days",
+		[]string{"AccountsPayable", "COGS"}, nil,
+		func(in ratioInputs) (float64, string) {
+			r, note := in.div(in.v["AccountsPayable"], in.v["COGS"], "COGS")
+			return r * in.days, note
+		}},
+	{"Cash Conversion Cycle", "turnover", "Days Sales Outstanding + Days Inventory Outstanding - Days Payables Outstanding",
+		[]string{"AccountsReceivable", "TotalRevenue", "Inventory", "COGS", "AccountsPayable"}, nil,
+		func(in ratioInputs) (float64, string) {
+			if in.v["TotalRevenue"] == 0 {
+				return 0, "TotalRevenue is zero"
+			}
+			if in.v["COGS"] == 0 {
+				return 0, "COGS is zero"
+			}
+			return in.days * (in.v["AccountsReceivable"]/in.v["TotalRevenue"] +
+				(in.v["Inventory"]-in.v["AccountsPayable"])/in.v["COGS"]), ""
+		}},
+
+	{"EPS (Diluted)", "per share", "EPSDiluted", []string{"EPSDiluted"}, nil,
+		func(in ratioInputs) (float64, string) { return in.v["EPSDiluted"], "" }},
+	simpleRatio("Revenue per Share", "per share", "TotalRevenue", "DilutedShares"),
+	simpleRatio("Book Value per Share", "per share", "TotalEquity", "DilutedShares"),
+	{"FCF per Share", "per share", "(OperatingCashFlow - CapitalExpenditures) / DilutedShares",
+		[]string{"OperatingCashFlow", "DilutedShares"}, []string{"CapitalExpenditures"},
+		func(in ratioInputs) (float64, string) {
+			return in.div(in.fcf(), in.v["DilutedShares"], "DilutedShares")
+		}},
+	{"Dividends per Share", "per share", "DividendsPerShare", []string{"DividendsPerShare"}, nil,
+		func(in ratioInputs) (float64, string) { return in.v["DividendsPerShare"], "" }},
+
+	{"Free Cash Flow", "cash conversion", "OperatingCashFlow - CapitalExpenditures",
+		[]string{"OperatingCashFlow"}, []string{"CapitalExpenditures"},
+		func(in ratioInputs) (float64, string) { return in.fcf(), "" }},
+	simpleRatio("Cash Conversion", "cash conversion", "OperatingCashFlow", "NetIncome"),
+	{"FCF Conversion", "cash conversion", "(OperatingCashFlow - CapitalExpenditures) / NetIncome",
+		[]string{"OperatingCashFlow", "NetIncome"}, []string{"CapitalExpenditures"},
+		func(in ratioInputs) (float64, string) { return in.div(in.fcf(), in.v["NetIncome"], "NetIncome") }},
+}
+
+// Returns the length in days of a column of the report, taken from its
+// revenue, or a nominal year or quarter when revenue was not reported
+func (r *FinancialStatement) periodDays(col int) float64 {
+	for _, s := range r.Standardized {
+		if s.Name == "TotalRevenue" && s.Data[col] != nil {
+			if d := durationDays(&s.Data[col].UnitEntry); d > 0 {
+				return float64(d)
+			}
+		}
+	}
+	if r.Periods[col].ForPeriod == "FY" {
+		return 365
+	}
+	return 91
+}
+
+// Computes every ratio for every column of the report from its standardized
+// statements. Balance sheet inputs are ending balances.
+func (r *FinancialStatement) computeRatios() []Ratio {
+	ratios := make([]Ratio, 0, len(ratioDefs))
+	for _, def := range ratioDefs {
+		ratio := Ratio{Name: def.name, Category: def.category, Formula: def.formula}
+		for col, p := range r.Periods {
+			rv := RatioValue{Period: p.Label}
+			in := ratioInputs{v: make(map[string]float64), days: r.periodDays(col)}
+			for _, name := range def.required {
+				v, ok := r.standardNumber(name, col)
+				if !ok {
+					rv.Missing = append(rv.Missing, name)
+				}
+				in.v[name] = v
+			}
+			for _, name := range def.optional {
+				v, ok := r.standardNumber(name, col)
+				if !ok {
+					rv.Assumed = append(rv.Assumed, name)
+				}
+				in.v[name] = v
+			}
+			if len(rv.Missing) == 0 {
+				v, note := def.compute(in)
+				if note != "" {
+					rv.Note = note
+				} else {
+					rv.Value = &v
+				}
+			}
+			ratio.Values = append(ratio.Values, rv)
+		}
+		ratios = append(ratios, ratio)
+	}
+	return ratios
+}
+
+// Formats a ratio value for tables, "n/a" when it could not be computed
+func (v RatioValue) String() string {
+	if v.Value == nil {
+		return "n/a"
+	}
+	return strconv.FormatFloat(*v.Value, 'f', 4, 64)
+}
+
+// Returns the rows of the ratios for the pivoted report
+func ratioRows(ratios []Ratio) [][]string {
+	rows := make([][]string, 0, len(ratios))
+	for _, ratio := range ratios {
+		row := []string{"Ratios/" + ratio.Category, ratio.Name, ratio.Formula}
+		for _, v := range ratio.Values {
+			if v.Value == nil {
+				row = append(row, "")
+			} else {
+				row = append(row, strconv.FormatFloat(*v.Value, 'g', -1, 64))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Holds the arguments of the ratios subcommand. The report is selected as
+// with get.
+type RatiosConfig struct {
+	GetConfig
+	Output string // text, json or csv
+}
+
+func (rc *RatiosConfig) HandleRatios() {
+	if rc.Ticker == "" && rc.CIK == "" {
+		fmt.Println("Error: expected a ticker, e.g. edgar ratios AAPL. Exiting...")
+		os.Exit(1)
+	}
+	c := checkConfig()
+	rc.resolveCIK(c)
+	r := rc.buildReport(c)
+	switch rc.Output {
+	case "json":
+		b, err := json.MarshalIndent(r.Ratios, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal ratios! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	case "csv":
+		header := []string{"category", "ratio", "formula"}
+		for _, p := range r.Periods {
+			header = append(header, p.Label)
+		}
+		w := csv.NewWriter(os.Stdout)
+		err := w.WriteAll(append([][]string{header}, ratioRows(r.Ratios)...))
+		if err != nil {
+			fmt.Printf("Error: could not write ratios! (%v)\n", err)
+			os.Exit(1)
+		}
+	default:
+		printRatios(r)
+	}
+}
+
+// Prints the ratios as a table, followed by the inputs missing for any n/a
+func printRatios(r *FinancialStatement) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"ratio"}
+	for _, p := range r.Periods {
+		header = append(header, p.Label)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	notes := make([]string, 0)
+	for _, ratio := range r.Ratios {
+		row := []string{ratio.Name}
+		for _, v := range ratio.Values {
+			row = append(row, v.String())
+			switch {
+			case len(v.Missing) > 0:
+				notes = append(notes, fmt.Sprintf("%s %s: missing %s", ratio.Name, v.Period, strings.Join(v.Missing, ", ")))
+			case v.Note != "":
+				notes = append(notes, fmt.Sprintf("%s %s: %s", ratio.Name, v.Period, v.Note))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+	if len(notes) > 0 {
+		fmt.Println()
+		for _, n := range notes {
+			fmt.Println(n)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"fmt"
+	"os"
+)
+
+// A ReportSection names one group of line items of a FinancialStatement,
+// e.g. "Income Statement/Revenue"
+type ReportSection struct {
+	Name  string
+	Items *[]LineItem
+}
+
+// Returns the sections of the report in statement order
+func (r *FinancialStatement) Sections() []ReportSection {
+	return []ReportSection{
+		{"Income Statement/Revenue", &r.IncomeStatement.Revenue},
+		{"Income Statement/Cost of Revenue", &r.IncomeStatement.CostOfRevenue},
+		{"Income Statement/Gross Profit", &r.IncomeStatement.GrossProfit},
+		{"Income Statement/Operating Expenses", &r.IncomeStatement.OperatingExpenses},
+		{"Income Statement/Operating Income/Loss", &r.IncomeStatement.OperatingIncomeLoss},
+		{"Income Statement/Other Income/Expense", &r.IncomeStatement.OtherIncomeExpense},
+		{"Income Statement/Income Before Tax", &r.IncomeStatement.IncomeBeforeTax},
+		{"Income Statement/Income Tax", &r.IncomeStatement.IncomeTax},
+		{"Income Statement/Net Income/Loss", &r.IncomeStatement.NetIncomeLoss},
+		{"Balance Sheet/Current Assets", &r.BalanceSheet.Assets.CurrentAssets},
+		{"Balance Sheet/Non-Current Assets", &r.BalanceSheet.Assets.NonCurrentAssets},
+		{"Balance Sheet/Total Assets", &r.BalanceSheet.Assets.TotalAssets},
+		{"Balance Sheet/Current Liabilities", &r.BalanceSheet.Liabilities.CurrentLiabilities},
+		{"Balance Sheet/Non-Current Liabilities", &r.BalanceSheet.Liabilities.NonCurrentLiabilities},
+		{"Balance Sheet/Total Liabilities", &r.BalanceSheet.Liabilities.TotalLiabilities},
+		{"Balance Sheet/Equity", &r.BalanceSheet.Equity},
+		{"Balance Sheet/Total Liabilities and Equity", &r.BalanceSheet.TotalLiabilitiesAndEquity},
+		{"Cash Flow Statement/Operating Activities", &r.CashFlowStatement.OperatingActivities},
+		{"Cash Flow Statement/Investing Activities", &r.CashFlowStatement.InvestingActivities},
+		{"Cash Flow Statement/Financing Activities", &r.CashFlowStatement.FinancingActivities},
+		{"Cash Flow Statement/Cash and Cash Equivalents", &r.CashFlowStatement.CashAndCashEquivalents},
+		{"Other Comprehensive Income", &r.OtherComprehensiveIncome},
+		{"Financial Metrics and Ratios", &r.FinancialMetricsAndRatios},
+		{"Share-Based Compensation", &r.ShareBasedCompensation},
+		{"Taxes", &r.Taxes},
+		{"Leases", &r.Leases},
+		{"Debt and Borrowings", &r.DebtAndBorrowings},
+		{"Intangible Assets and Goodwill", &r.IntangibleAssetsAndGoodwill},
+		{"Commitments and Contingencies", &r.CommitmentsAndContingencies},
+		{"Derivatives and Hedging", &r.DerivativesAndHedging},
+		{"Stock and Equity-related Items", &r.StockAndEquityRelatedItems},
+		{"Other Financial Items", &r.OtherFinancialItems},
+	}
+}
+
+// Returns the report pivoted into rows: a header of period labels, then one
+// row per line item with a value per period, empty where not reported. The
+// standardized statements and ratios come first. Trailing twelve months
+// values follow their line item as a row of their own.
+func (r *FinancialStatement) Rows() [][]string {
+	header := []string{"section", "item", "concept"}
+	for _, p := range r.Periods {
+		header = append(header, p.Label)
+	}
+	rows := append([][]string{header}, standardRows(r.Standardized)...)
+	rows = append(rows, ratioRows(r.Ratios)...)
+	for _, s := range r.Sections() {
+		for _, li := range *s.Items {
+			rows = append(rows, valueRow([]string{s.Name, li.Tag, li.Concept}, li.Data))
+			if li.TTM != nil {
+				rows = append(rows, valueRow([]string{s.Name, li.Tag + " (TTM)", li.Concept}, li.TTM))
+			}
+		}
+	}
+	return rows
+}
+
+func valueRow(row []string, values []*ReportValue) []string {
+	for _, v := range values {
+		if v == nil {
+			row = append(row, "")
+		} else {
+			row = append(row, v.Value.String())
+		}
+	}
+	return row
+}
+
+// Writes the report as CSV or TSV, following g.Format, to the app directory:
+// flattened into a row per value, or pivoted into a column per period with
+// -layout pivot
+func (g *GetConfig) downloadCSV(r *FinancialStatement) error {
+	err := createDir("app/" + g.Ticker + "/")
+	if err != nil {
+		fmt.Printf("Error: could not create 'app' directory! (%v)\n", err)
+		return err
+	}
+	f, err := os.Create("./app/" + g.Ticker + "/" + g.RawFile + "." + g.Format)
+	if err != nil {
+		fmt.Printf("Error: could not create file in app dir! (%v)\n", err)
+		return err
+	}
+	defer f.Close()
+	rows := r.FlatRows()
+	if g.Layout == "pivot" {
+		rows = r.Rows()
+	}
+	err = writeDelimited(f, g.Format, rows)
+	if err != nil {
+		fmt.Printf("Error: could not write file to app dir! (%v)\n", err)
+		return err
+	}
+	return nil
+}
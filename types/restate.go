@@ -0,0 +1,199 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// A ReportedValue is the value one filing reported for a concept and period
+type ReportedValue struct {
+	Value      json.Number `json:"val"`
+	Accession  string      `json:"accn"`
+	Form       string      `json:"form"`
+	Filed      string      `json:"filed"`
+	FiscalYear int         `json:"fy"`
+	ForPeriod  string      `json:"fp"`
+	Changed    bool        `json:"changed"`              // differs from the value reported before it
+	Difference json.Number `json:"difference,omitempty"` // from the value reported before it
+}
+
+// A FactHistory lists every value reported for a concept over one period.
+// Each filing repeats earlier periods as comparatives, so a period is
+// usually reported several times; it was restated when a later filing
+// changed the number.
+type FactHistory struct {
+	Concept  string          `json:"concept"`
+	Unit     string          `json:"unit"`
+	Start    string          `json:"start,omitempty"`
+	End      string          `json:"end"`
+	Restated bool            `json:"restated"`
+	Reports  []ReportedValue `json:"reports"` // oldest filing first
+}
+
+// Returns the difference b - a, and whether the values differ. Values that
+// are not numbers differ when their text does.
+func valueChange(a, b json.Number) (json.Number, bool) {
+	d, ok := sumValues([]*UnitEntry{{Value: b}}, []*UnitEntry{{Value: a}})
+	if !ok {
+		return "", a != b
+	}
+	return d, d != "0"
+}
+
+// Returns the entry first reporting the period of e, which is e itself
+// unless an earlier filing reported the same concept and period
+func firstReported(entries []UnitEntry, e *UnitEntry) *UnitEntry {
+	first := e
+	for i := range entries {
+		v := &entries[i]
+		if v.PeriodStart != e.PeriodStart || v.PeriodEnd != e.PeriodEnd {
+			continue
+		}
+		if v.Filed < first.Filed || v.Filed == first.Filed && v.Accession < first.Accession {
+			first = v
+		}
+	}
+	return first
+}
+
+// Builds the history of every period of the given concepts, or of all
+// concepts if none are given, sorted by concept, unit and period
+func factHistory(f *CompanyFacts, concepts []string) []FactHistory {
+	wanted := make(map[string]bool, len(concepts))
+	for _, c := range concepts {
+		wanted[c] = true
+	}
+	type key struct{ concept, unit, start, end string }
+	groups := make(map[key]*FactHistory)
+	for concept, fact := range f.Facts.Data {
+		if len(wanted) > 0 && !wanted[concept] {
+			continue
+		}
+		for unit, entries := range fact.Units.byUnit() {
+			for _, e := range entries {
+				k := key{concept, unit, e.PeriodStart, e.PeriodEnd}
+				h, ok := groups[k]
+				if !ok {
+					h = &FactHistory{Concept: concept, Unit: unit, Start: e.PeriodStart, End: e.PeriodEnd}
+					groups[k] = h
+				}
+				h.Reports = append(h.Reports, ReportedValue{
+					Value:      e.Value,
+					Accession:  e.Accession,
+					Form:       e.Form,
+					Filed:      e.Filed,
+					FiscalYear: e.FiscalYear,
+					ForPeriod:  e.ForPeriod,
+				})
+			}
+		}
+	}
+	history := make([]FactHistory, 0, len(groups))
+	for _, h := range groups {
+		sort.SliceStable(h.Reports, func(i, j int) bool {
+			if h.Reports[i].Filed != h.Reports[j].Filed {
+				return h.Reports[i].Filed < h.Reports[j].Filed
+			}
+			return h.Reports[i].Accession < h.Reports[j].Accession
+		})
+		for i := 1; i < len(h.Reports); i++ {
+			d, changed := valueChange(h.Reports[i-1].Value, h.Reports[i].Value)
+			if changed {
+				h.Reports[i].Changed, h.Reports[i].Difference = true, d
+				h.Restated = true
+			}
+		}
+		history = append(history, *h)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		switch {
+		case a.Concept != b.Concept:
+			return a.Concept < b.Concept
+		case a.Unit != b.Unit:
+			return a.Unit < b.Unit
+		case a.End != b.End:
+			return a.End < b.End
+		}
+		return a.Start < b.Start
+	})
+	return history
+}
+
+// Holds the arguments of the restatements subcommand
+type RestatementsConfig struct {
+	GetConfig
+	Concepts string // comma separated concepts, empty for all
+	All      bool   // list every period, not only restated ones
+	Output   string // text or json
+}
+
+func (rc *RestatementsConfig) HandleRestatements() {
+	if rc.Ticker == "" && rc.CIK == "" {
+		fmt.Println("Error: expected a ticker, e.g. edgar restatements AAPL. Exiting...")
+		os.Exit(1)
+	}
+	c := checkConfig()
+	rc.resolveCIK(c)
+	facts := rc.loadCompanyFacts(c, assembleUrl(rc.CIK, companyFacts))
+	var concepts []string
+	if rc.Concepts != "" {
+		for _, name := range strings.Split(rc.Concepts, ",") {
+			concepts = append(concepts, strings.TrimSpace(name))
+		}
+	}
+	history := make([]FactHistory, 0)
+	restated := 0
+	for _, h := range factHistory(facts, concepts) {
+		if h.Restated {
+			restated++
+		}
+		if h.Restated || rc.All {
+			history = append(history, h)
+		}
+	}
+
+	switch rc.Output {
+	case "json":
+		b, err := json.MarshalIndent(history, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal restatements! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	default:
+		printHistory(history)
+	}
+	fmt.Fprintf(os.Stderr, "%d restated periods in the company facts of %s\n", restated, facts.EntityName)
+}
+
+// Prints each period followed by the filings that reported it, flagging
+// the values a later filing changed
+func printHistory(history []FactHistory) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, h := range history {
+		period := h.End
+		if h.Start != "" {
+			period = h.Start + " to " + h.End
+		}
+		fmt.Fprintf(w, "%s (%s) %s\n", h.Concept, h.Unit, period)
+		for _, r := range h.Reports {
+			note := ""
+			switch {
+			case !r.Changed:
+			case r.Difference == "":
+				note = "restated"
+			case strings.HasPrefix(string(r.Difference), "-"):
+				note = fmt.Sprintf("restated (%s)", r.Difference)
+			default:
+				note = fmt.Sprintf("restated (+%s)", r.Difference)
+			}
+			fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\t%s\n", r.Filed, r.Form, r.Accession, r.Value, note)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,492 @@
+package types
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Score is the result of one scoring model for one company and fiscal
+// year. When inputs are missing, Value is nil and InsufficientData lists
+// them; the components that could be computed are still reported.
+type Score struct {
+	Model            string           `json:"model"`
+	Ticker           string           `json:"ticker"`
+	FiscalYear       int              `json:"fy"`
+	Value            *float64         `json:"value"`
+	Interpretation   string           `json:"interpretation,omitempty"`
+	InsufficientData []string         `json:"insufficient_data,omitempty"`
+	Components       []ScoreComponent `json:"components"`
+}
+
+// A ScoreComponent is one term of a scoring model
+type ScoreComponent struct {
+	Name    string       `json:"name"`
+	Formula string       `json:"formula"`
+	Weight  float64      `json:"weight"`
+	Value   *float64     `json:"value"` // before weighting
+	Inputs  []ScoreInput `json:"inputs"`
+	Note    string       `json:"note,omitempty"`
+}
+
+// A ScoreInput is a value a component was computed from and the concept it
+// was read from
+type ScoreInput struct {
+	Line    string  `json:"line"` // standardized line, see standardConcepts
+	Period  string  `json:"period"`
+	Concept string  `json:"concept"`
+	Value   float64 `json:"value"`
+}
+
+// Computes components over the current and prior fiscal year columns of a
+// report
+type scoring struct {
+	r         *FinancialStatement
+	cur, prev int // columns, -1 when the year is not in the report
+}
+
+// Computes a component. The compute function reads standardized lines with
+// get, for the current year or the prior year, and returns false with a note
+// when the component is undefined, e.g. on division by zero.
+func (s *scoring) component(name, formula string, weight float64, compute func(get func(line string, prior bool) float64) (float64, string)) ScoreComponent {
+	c := ScoreComponent{Name: name, Formula: formula, Weight: weight, Inputs: make([]ScoreInput, 0)}
+	missing := make([]string, 0)
+	get := func(line string, prior bool) float64 {
+		col := s.cur
+		if prior {
+			col = s.prev
+		}
+		if col < 0 {
+			missing = append(missing, line+" (prior year)")
+			return 0
+		}
+		period := s.r.Periods[col].Label
+		for _, sl := range s.r.Standardized {
+			if sl.Name == line && sl.Data[col] != nil {
+				v, ok := s.r.standardNumber(line, col)
+				if ok {
+					c.Inputs = append(c.Inputs, ScoreInput{Line: line, Period: period, Concept: sl.Data[col].Concept, Value: v})
+					return v
+				}
+			}
+		}
+		missing = append(missing, line+" ("+period+")")
+		return 0
+	}
+	v, note := compute(get)
+	switch {
+	case len(missing) > 0:
+		c.Note = "missing " + strings.Join(missing, ", ")
+	case note != "":
+		c.Note = note
+	default:
+		c.Value = &v
+	}
+	return c
+}
+
+// Divides a by b, with a note on division by zero
+func quotient(a, b float64, den string) (float64, string) {
+	if b == 0 {
+		return 0, den + " is zero"
+	}
+	return a / b, ""
+}
+
+// Adds up the weighted components into a score. Any component that could
+// not be computed leaves the score with insufficient data.
+func weightedScore(model string, constant float64, components []ScoreComponent) Score {
+	sc := Score{Model: model, Components: components}
+	total := constant
+	for _, c := range components {
+		if c.Value == nil {
+			sc.InsufficientData = append(sc.InsufficientData, c.Name+": "+c.Note)
+			continue
+		}
+		total += c.Weight * *c.Value
+	}
+	if len(sc.InsufficientData) == 0 {
+		sc.Value = &total
+	}
+	return sc
+}
+
+// Altman Z' score, the version for firms without a market value of equity.
+// companyfacts does not report market capitalization, so book equity stands
+// in for it as Altman (1983) prescribes.
+func (s *scoring) altmanZ() Score {
+	ta := func(get func(string, bool) float64) float64 { return get("TotalAssets", false) }
+	sc := weightedScore("Altman Z'", 0, []ScoreComponent{
+		s.component("X1 working capital", "(TotalCurrentAssets - TotalCurrentLiabilities) / TotalAssets", 0.717,
+			func(get func(string, bool) float64) (float64, string) {
+				return quotient(get("TotalCurrentAssets", false)-get("TotalCurrentLiabilities", false), ta(get), "TotalAssets")
+			}),
+		s.component("X2 retained earnings", "RetainedEarnings / TotalAssets", 0.847,
+			func(get func(string, bool) float64) (float64, string) {
+				return quotient(get("RetainedEarnings", false), ta(get), "TotalAssets")
+			}),
+		s.component("X3 EBIT", "EBIT / TotalAssets", 3.107,
+			func(get func(string, bool) float64) (float64, string) {
+				return quotient(get("EBIT", false), ta(get), "TotalAssets")
+			}),
+		s.component("X4 book equity", "TotalEquity / TotalLiabilities", 0.420,
+			func(get func(string, bool) float64) (float64, string) {
+				return quotient(get("TotalEquity", false), get("TotalLiabilities", false), "TotalLiabilities")
+			}),
+		s.component("X5 sales", "TotalRevenue / TotalAssets", 0.998,
+			func(get func(string, bool) float64) (float64, string) {
+				return quotient(get("TotalRevenue", false), ta(get), "TotalAssets")
+			}),
+	})
+	if sc.Value != nil {
+		switch z := *sc.Value; {
+		case z > 2.9:
+			sc.Interpretation = "safe"
+		case z < 1.23:
+			sc.Interpretation = "distress"
+		default:
+			sc.Interpretation = "grey"
+		}
+	}
+	return sc
+}
+
+// Returns 1 if the condition holds, 0 otherwise
+func binary(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// Piotroski F score: nine binary signals of profitability, leverage and
+// liquidity, and operating efficiency, comparing the year with the prior
+// year. Return on assets uses ending total assets.
+func (s *scoring) piotroskiF() Score {
+	type getter = func(string, bool) float64
+	roa := func(get getter, prior bool) (float64, string) {
+		return quotient(get("NetIncome", prior), get("TotalAssets", prior), "TotalAssets")
+	}
+	sc := weightedScore("Piotroski F", 0, []ScoreComponent{
+		s.component("ROA", "NetIncome / TotalAssets > 0", 1, func(get getter) (float64, string) {
+			r, note := roa(get, false)
+			return binary(r > 0), note
+		}),
+		s.component("CFO", "OperatingCashFlow > 0", 1, func(get getter) (float64, string) {
+			return binary(get("OperatingCashFlow", false) > 0), ""
+		}),
+		s.component("ΔROA", "ROA > prior ROA", 1, func(get getter) (float64, string) {
+			r, note := roa(get, false)
+			p, pnote := roa(get, true)
+			return binary(r > p), yearNotes(note, pnote)
+		}),
+		s.component("Accrual", "OperatingCashFlow > NetIncome", 1, func(get getter) (float64, string) {
+			return binary(get("OperatingCashFlow", false) > get("NetIncome", false)), ""
+		}),
+		s.component("ΔLeverage", "LongTermDebt / TotalAssets < prior", 1, func(get getter) (float64, string) {
+			l, note := quotient(get("LongTermDebt", false), get("TotalAssets", false), "TotalAssets")
+			p, pnote := quotient(get("LongTermDebt", true), get("TotalAssets", true), "TotalAssets")
+			return binary(l < p), yearNotes(note, pnote)
+		}),
+		s.component("ΔLiquidity", "TotalCurrentAssets / TotalCurrentLiabilities > prior", 1, func(get getter) (float64, string) {
+			c, note := quotient(get("TotalCurrentAssets", false), get("TotalCurrentLiabilities", false), "TotalCurrentLiabilities")
+			p, pnote := quotient(get("TotalCurrentAssets", true), get("TotalCurrentLiabilities", true), "TotalCurrentLiabilities")
+			return binary(c > p), yearNotes(note, pnote)
+		}),
+		s.component("No dilution", "DilutedShares <= prior", 1, func(get getter) (float64, string) {
+			return binary(get("DilutedShares", false) <= get("DilutedShares", true)), ""
+		}),
+		s.component("ΔGross margin", "GrossProfit / TotalRevenue > prior", 1, func(get getter) (float64, string) {
+			m, note := quotient(get("GrossProfit", false), get("TotalRevenue", false), "TotalRevenue")
+			p, pnote := quotient(get("GrossProfit", true), get("TotalRevenue", true), "TotalRevenue")
+			return binary(m > p), yearNotes(note, pnote)
+		}),
+		s.component("ΔAsset turnover", "TotalRevenue / TotalAssets > prior", 1, func(get getter) (float64, string) {
+			t, note := quotient(get("TotalRevenue", false), get("TotalAssets", false), "TotalAssets")
+			p, pnote := quotient(get("TotalRevenue", true), get("TotalAssets", true), "TotalAssets")
+			return binary(t > p), yearNotes(note, pnote)
+		}),
+	})
+	if sc.Value != nil {
+		switch f := *sc.Value; {
+		case f >= 8:
+			sc.Interpretation = "strong"
+		case f <= 2:
+			sc.Interpretation = "weak"
+		default:
+			sc.Interpretation = "average"
+		}
+	}
+	return sc
+}
+
+// Joins the notes of a component's current and prior year values, marking
+// which year each belongs to
+func yearNotes(note, prior string) string {
+	notes := make([]string, 0, 2)
+	if note != "" {
+		notes = append(notes, note)
+	}
+	if prior != "" {
+		notes = append(notes, "prior year: "+prior)
+	}
+	return strings.Join(notes, "; ")
+}
+
+// Beneish M score: eight indices of earnings manipulation comparing the year
+// with the prior year. The asset quality index leaves out securities, which
+// have no standardized line.
+func (s *scoring) beneishM() Score {
+	type getter = func(string, bool) float64
+	// Divides the ratio of the current year by the ratio of the prior year
+	index := func(get getter, ratio func(prior bool) (float64, string)) (float64, string) {
+		c, note := ratio(false)
+		p, pnote := ratio(true)
+		if note != "" || pnote != "" {
+			return 0, yearNotes(note, pnote)
+		}
+		return quotient(c, p, "prior year ratio")
+	}
+	sc := weightedScore("Beneish M", -4.84, []ScoreComponent{
+		s.component("DSRI", "(AccountsReceivable / TotalRevenue) / prior", 0.920, func(get getter) (float64, string) {
+			return index(get, func(prior bool) (float64, string) {
+				return quotient(get("AccountsReceivable", prior), get("TotalRevenue", prior), "TotalRevenue")
+			})
+		}),
+		s.component("GMI", "prior (GrossProfit / TotalRevenue) / current", 0.528, func(get getter) (float64, string) {
+			m, note := index(get, func(prior bool) (float64, string) {
+				return quotient(get("GrossProfit", prior), get("TotalRevenue", prior), "TotalRevenue")
+			})
+			if note != "" {
+				return 0, note
+			}
+			return quotient(1, m, "gross margin")
+		}),
+		s.component("AQI", "(1 - (TotalCurrentAssets + PP&E) / TotalAssets) / prior", 0.404, func(get getter) (float64, string) {
+			return index(get, func(prior bool) (float64, string) {
+				q, note := quotient(get("TotalCurrentAssets", prior)+get("PP&E", prior), get("TotalAssets", prior), "TotalAssets")
+				return 1 - q, note
+			})
+		}),
+		s.component("SGI", "TotalRevenue / prior", 0.892, func(get getter) (float64, string) {
+			return quotient(get("TotalRevenue", false), get("TotalRevenue", true), "prior TotalRevenue")
+		}),
+		s.component("DEPI", "prior (DepreciationAndAmortization / (DepreciationAndAmortization + PP&E)) / current", 0.115, func(get getter) (float64, string) {
+			d, note := index(get, func(prior bool) (float64, string) {
+				da := get("DepreciationAndAmortization", prior)
+				return quotient(da, da+get("PP&E", prior), "DepreciationAndAmortization + PP&E")
+			})
+			if note != "" {
+				return 0, note
+			}
+			return quotient(1, d, "depreciation rate")
+		}),
+		s.component("SGAI", "(SG&A / TotalRevenue) / prior", -0.172, func(get getter) (float64, string) {
+			return index(get, func(prior bool) (float64, string) {
+				return quotient(get("SG&A", prior), get("TotalRevenue", prior), "TotalRevenue")
+			})
+		}),
+		s.component("TATA", "(NetIncome - OperatingCashFlow) / TotalAssets", 4.679, func(get getter) (float64, string) {
+			return quotient(get("NetIncome", false)-get("OperatingCashFlow", false), get("TotalAssets", false), "TotalAssets")
+		}),
+		s.component("LVGI", "((TotalCurrentLiabilities + LongTermDebt) / TotalAssets) / prior", -0.327, func(get getter) (float64, string) {
+			return index(get, func(prior bool) (float64, string) {
+				return quotient(get("TotalCurrentLiabilities", prior)+get("LongTermDebt", prior), get("TotalAssets", prior), "TotalAssets")
+			})
+		}),
+	})
+	if sc.Value != nil {
+		if *sc.Value > -1.78 {
+			sc.Interpretation = "likely manipulator"
+		} else {
+			sc.Interpretation = "unlikely manipulator"
+		}
+	}
+	return sc
+}
+
+// Returns the latest fiscal year reported in an annual report, such as a
+// 10-K, 20-F or 40-F
+func latestFiscalYear(f *CompanyFacts) int {
+	latest := 0
+	for _, fact := range f.Facts.Data {
+		for _, v := range fact.Units.USD {
+			if inFormGroup(v.Form, "annual") && v.ForPeriod == "FY" && v.FiscalYear > latest {
+				latest = v.FiscalYear
+			}
+		}
+	}
+	return latest
+}
+
+// Scores a company for fiscal year fy, or its latest fiscal year if fy is 0,
+// from the annual reports of that year and the year before
+func (g *GetConfig) scores(f *CompanyFacts, xbrl *XBRLTags, fy int) ([]Score, error) {
+	if fy == 0 {
+		fy = latestFiscalYear(f)
+	}
+	g.Doc, g.Quarters, g.Years, g.Sections = "annual", 0, fmt.Sprintf("%d-%d", fy-1, fy), "none"
+	r, err := g.assembleReport(f, xbrl)
+	if err != nil {
+		return nil, err
+	}
+	s := &scoring{r: r, cur: -1, prev: -1}
+	for col, p := range r.Periods {
+		switch p.FiscalYear {
+		case fy:
+			s.cur = col
+		case fy - 1:
+			s.prev = col
+		}
+	}
+	if s.cur < 0 {
+		return nil, fmt.Errorf("no annual report for fiscal year %d", fy)
+	}
+	scores := []Score{s.altmanZ(), s.piotroskiF(), s.beneishM()}
+	for i := range scores {
+		scores[i].Ticker, scores[i].FiscalYear = g.Ticker, fy
+	}
+	return scores, nil
+}
+
+// Holds the arguments of the scores subcommand
+type ScoresConfig struct {
+	GetConfig
+	Tickers   []string // positional tickers
+	List      string   // comma separated tickers
+	Watchlist string   // file of tickers, one per line
+	Year      int      // fiscal year, latest if 0
+	Output    string   // text or json
+}
+
+// Returns the tickers to score: positional, -tickers and -watchlist
+func (sc *ScoresConfig) watchlist() ([]string, error) {
+	tickers := append([]string{}, sc.Tickers...)
+	if sc.List != "" {
+		tickers = append(tickers, strings.Split(sc.List, ",")...)
+	}
+	if sc.Watchlist != "" {
+		listed, err := readWatchlist(sc.Watchlist)
+		if err != nil {
+			return nil, err
+		}
+		tickers = append(tickers, listed...)
+	}
+	for i := range tickers {
+		tickers[i] = strings.ToUpper(strings.TrimSpace(tickers[i]))
+	}
+	return tickers, nil
+}
+
+// Reads a file of tickers, one or more comma separated per line. Blank lines
+// and lines starting with # are skipped.
+func readWatchlist(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tickers := make([]string, 0)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tickers = append(tickers, strings.Split(line, ",")...)
+		}
+	}
+	return tickers, s.Err()
+}
+
+func (sc *ScoresConfig) HandleScores() {
+	tickers, err := sc.watchlist()
+	if err != nil {
+		fmt.Printf("Error: could not read watchlist! (%v)\n", err)
+		os.Exit(1)
+	}
+	if len(tickers) == 0 && sc.CIK == "" {
+		fmt.Println("Error: expected tickers, e.g. edgar scores AAPL MSFT or -watchlist file. Exiting...")
+		os.Exit(1)
+	}
+	if len(tickers) == 0 {
+		tickers = []string{""}
+	}
+	c := checkConfig()
+	xbrl := getXBRLTags(sc.Mapping)
+	var ciks map[string]int
+	all := make([]Score, 0)
+	for _, t := range tickers {
+		g := sc.GetConfig
+		g.Ticker = t
+		if g.CIK == "" {
+			if ciks == nil {
+				ciks = c.checkCompanyTickers()
+			}
+			cik, ok := ciks[t]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: ticker %s not found!\n", t)
+				all = append(all, Score{Ticker: t, InsufficientData: []string{"ticker not found"}})
+				continue
+			}
+			g.CIK = zeroPad(fmt.Sprint(cik))
+		}
+		facts, err := g.companyFacts(c, assembleUrl(g.CIK, companyFacts))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not load %s! (%v)\n", t, err)
+			all = append(all, Score{Ticker: t, FiscalYear: sc.Year, InsufficientData: []string{err.Error()}})
+			continue
+		}
+		scores, err := g.scores(facts, xbrl, sc.Year)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not score %s! (%v)\n", t, err)
+			all = append(all, Score{Ticker: t, FiscalYear: sc.Year, InsufficientData: []string{err.Error()}})
+			continue
+		}
+		all = append(all, scores...)
+	}
+
+	if sc.Output == "json" {
+		b, err := json.MarshalIndent(all, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal scores! (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+		return
+	}
+	printScores(all)
+}
+
+// Prints each score followed by its components and the concepts they used
+func printScores(scores []Score) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range scores {
+		if s.Model == "" {
+			fmt.Fprintf(w, "%s\tinsufficient data: %s\n", s.Ticker, strings.Join(s.InsufficientData, "; "))
+			continue
+		}
+		if s.Value == nil {
+			fmt.Fprintf(w, "%s FY%d\t%s\tinsufficient data\n", s.Ticker, s.FiscalYear, s.Model)
+		} else {
+			fmt.Fprintf(w, "%s FY%d\t%s\t%.2f\t%s\n", s.Ticker, s.FiscalYear, s.Model, *s.Value, s.Interpretation)
+		}
+		for _, c := range s.Components {
+			value := "n/a"
+			if c.Value != nil {
+				value = fmt.Sprintf("%.4f", *c.Value)
+			}
+			concepts := make([]string, 0, len(c.Inputs))
+			for _, in := range c.Inputs {
+				concepts = append(concepts, fmt.Sprintf("%s=%s[%s]", in.Line, in.Concept, in.Period))
+			}
+			detail := strings.Join(concepts, " ")
+			if c.Note != "" {
+				detail = c.Note
+			}
+			fmt.Fprintf(w, "\t  %s\t%s\tx %.3f\t%s\n", c.Name, value, c.Weight, detail)
+		}
+	}
+	w.Flush()
+}
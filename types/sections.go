@@ -0,0 +1,232 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A Section is one standard item of a 10-K or 10-Q, e.g. Item 1A Risk Factors
+type Section struct {
+	Part    string    `json:"part,omitempty"` // roman part number, e.g. "II"
+	Item    string    `json:"item"`           // upper-cased item number, e.g. "1A"
+	Title   string    `json:"title"`
+	Text    string    `json:"text"`
+	Offsets TextRange `json:"offsets"`
+}
+
+// Byte offsets of a section within the parsed filing text, heading included
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+var (
+	// "Item 1A. Risk Factors", "ITEM 7 – MANAGEMENT'S ...", "Item 8:" ...
+	// The item letter is upper case and part of the number, so the first
+	// letter of "Item 1 Business" stays with the title
+	itemHeading = regexp.MustCompile(`^(?i:item)\s*(\d{1,2}[A-D]?)\b\s*[.:\-–—]*\s*(.*)$`)
+	// Table of contents rows end with a page number after a tab
+	tocRow = regexp.MustCompile(`\t\s*(?:[a-z]-)?\d{1,3}$`)
+	// Part headings sit between items and belong to neither
+	partHeading = regexp.MustCompile(`(?i)^part\s+([iv]+)\b.{0,80}$`)
+)
+
+// The longest line still treated as a heading
+const maxHeadingLength = 200
+
+// A heading candidate found in the text
+type itemCandidate struct {
+	part  string
+	item  string
+	title string
+	start int // offset of the heading line
+	body  int // offset of the first line after the heading (and its title)
+}
+
+// Splits the text produced by FilingText into its standard items. Each item
+// heading usually appears twice, in the table of contents and at the start
+// of the item; the occurrence followed by the most text is kept. Items are
+// told apart by part as well, since a 10-Q numbers the items of Part I and
+// Part II from 1 again.
+func ExtractSections(text string) []Section {
+	candidates := findItemHeadings(text)
+	if len(candidates) == 0 {
+		return []Section{}
+	}
+
+	// Text following each candidate up to the next candidate
+	length := func(i int) int {
+		end := len(text)
+		if i+1 < len(candidates) {
+			end = candidates[i+1].start
+		}
+		return end - candidates[i].body
+	}
+	best := make(map[string]int)
+	for i, c := range candidates {
+		key := sectionKey(c.part, c.item)
+		j, ok := best[key]
+		if !ok || length(i) > length(j) {
+			best[key] = i
+		}
+	}
+	chosen := make([]int, 0, len(best))
+	for _, i := range best {
+		chosen = append(chosen, i)
+	}
+	sort.Ints(chosen)
+
+	sections := make([]Section, 0, len(chosen))
+	for k, i := range chosen {
+		c := candidates[i]
+		end := len(text)
+		if k+1 < len(chosen) {
+			end = candidates[chosen[k+1]].start
+		}
+		sections = append(sections, Section{
+			Part:    c.part,
+			Item:    c.item,
+			Title:   c.title,
+			Text:    trimSectionText(text[c.body:end]),
+			Offsets: TextRange{Start: c.start, End: end},
+		})
+	}
+	return sections
+}
+
+// Returns every line that looks like an item heading, skipping table of
+// contents rows, cross references and "(continued)" page headers. Each
+// heading belongs to the part whose heading precedes it, if any.
+func findItemHeadings(text string) []itemCandidate {
+	lines := strings.SplitAfter(text, "\n")
+	candidates := make([]itemCandidate, 0)
+	offset := 0
+	part := ""
+	for i, raw := range lines {
+		start := offset
+		offset += len(raw)
+		line := strings.TrimSpace(raw)
+		if len(line) > maxHeadingLength {
+			continue
+		}
+		if m := partHeading.FindStringSubmatch(strings.ReplaceAll(line, "\t", " ")); m != nil {
+			part = strings.ToUpper(m[1])
+			continue
+		}
+		if tocRow.MatchString(line) {
+			continue
+		}
+		m := itemHeading.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		title := strings.TrimSpace(strings.ReplaceAll(m[2], "\t", " "))
+		if strings.Contains(strings.ToLower(title), "continued") {
+			continue
+		}
+		// A heading's title starts with a capital letter; "Item 8 for details"
+		// in running text does not
+		if title != "" && !startsUpper(title) {
+			continue
+		}
+		body := offset
+		// "Item 1A." alone on a line is followed by its title on the next line
+		if title == "" && i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if next != "" && len(next) <= maxHeadingLength && startsUpper(next) && !strings.HasSuffix(next, ".") {
+				title = next
+				body += len(lines[i+1])
+			}
+		}
+		candidates = append(candidates, itemCandidate{
+			part:  part,
+			item:  m[1],
+			title: strings.TrimRight(title, ". "),
+			start: start,
+			body:  body,
+		})
+	}
+	return candidates
+}
+
+// Identifies an item within its part, e.g. "II-1A", or by its number alone
+// when the filing has no part headings
+func sectionKey(part, item string) string {
+	if part == "" {
+		return item
+	}
+	return part + "-" + item
+}
+
+func startsUpper(s string) bool {
+	for _, r := range s {
+		return r >= 'A' && r <= 'Z'
+	}
+	return false
+}
+
+// Trims the section text and drops a trailing "PART II" style heading that
+// belongs to the next part rather than to the section
+func trimSectionText(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for len(lines) > 0 {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if last == "" || partHeading.MatchString(last) {
+			lines = lines[:len(lines)-1]
+			continue
+		}
+		break
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Keeps the sections in the comma separated list; "all" keeps every section.
+// An item qualified by its part, e.g. "II-1A", selects that part's item only,
+// while "1A" selects item 1A of every part.
+func filterSections(sections []Section, list string) []Section {
+	if strings.EqualFold(list, "all") {
+		return sections
+	}
+	wanted := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		wanted[strings.ToUpper(strings.TrimSpace(item))] = true
+	}
+	kept := make([]Section, 0)
+	for _, s := range sections {
+		if wanted[s.Item] || wanted[sectionKey(s.Part, s.Item)] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// Writes the requested sections of the parsed text as JSON next to the source
+func (p *ParseConfig) writeSections(text string) {
+	sections := filterSections(ExtractSections(text), p.Sections)
+	if len(sections) == 0 {
+		fmt.Printf("Error: no sections matching %s found in %s!\n", p.Sections, p.File)
+		os.Exit(1)
+	}
+	b, err := json.MarshalIndent(sections, "", "	")
+	if err != nil {
+		fmt.Printf("Error: could not marshal sections! (%v)\n", err)
+		os.Exit(1)
+	}
+	out := p.outputBase() + ".sections.json"
+	err = os.WriteFile(out, b, 0666)
+	if err != nil {
+		fmt.Printf("Error: could not write file! (%v)\n", err)
+		os.Exit(1)
+	}
+	for _, s := range sections {
+		if s.Part != "" {
+			fmt.Printf("Part %s, ", s.Part)
+		}
+		fmt.Printf("Item %s: %s (%d characters)\n", s.Item, s.Title, len(s.Text))
+	}
+	fmt.Printf("Parsed %s to %s\n", p.File, out)
+}
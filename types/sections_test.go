@@ -0,0 +1,146 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+// A 10-Q as rendered by FilingText: a table of contents followed by both
+// parts, whose items are numbered from 1 again
+const tenQText = `TABLE OF CONTENTS
+PART I — FINANCIAL INFORMATION
+Item 1.	Financial Statements	3
+Item 2.	Management's Discussion and Analysis	15
+PART II — OTHER INFORMATION
+Item 1.	Legal Proceedings	30
+Item 1A.	Risk Factors	30
+Item 6.	Exhibits	32
+
+PART I — FINANCIAL INFORMATION
+Item 1. Financial Statements
+Condensed consolidated balance sheets follow.
+Item 2. Management's Discussion and Analysis of Financial Condition and Results of Operations
+Revenue grew in the quarter.
+PART II — OTHER INFORMATION
+Item 1. Legal Proceedings
+See Note 9, Commitments and Contingencies.
+Item 1A. Risk Factors
+There have been no material changes to our risk factors.
+Item 6. Exhibits
+31.1 Certification of the Chief Executive Officer.
+`
+
+// A 10-K without part headings, where item numbers are unique
+const tenKText = `Item 1. Business
+We make things.
+Item 1A. Risk Factors
+Things may break.
+Item 7. Management's Discussion and Analysis
+Sales rose.
+`
+
+// Headings without a period, separated by a tab or in all caps, with titles
+// starting with the letters A-D that item numbers take as a suffix
+const plainHeadingsText = `Item 1 Business
+We make things.
+ITEM 1A RISK FACTORS
+Things may break.
+Item 4	Controls and Procedures
+Controls are effective.
+ITEM 7	MANAGEMENT'S DISCUSSION AND ANALYSIS
+Sales rose.
+Item 10 Directors, Executive Officers and Corporate Governance
+See the proxy statement.
+`
+
+func TestExtractSections(t *testing.T) {
+	type want struct {
+		part, item, title, text string
+	}
+	tests := []struct {
+		name string
+		text string
+		want []want
+	}{
+		{
+			name: "10-Q items repeat across parts",
+			text: tenQText,
+			want: []want{
+				{"I", "1", "Financial Statements", "Condensed consolidated balance sheets follow."},
+				{"I", "2", "Management's Discussion and Analysis of Financial Condition and Results of Operations", "Revenue grew in the quarter."},
+				{"II", "1", "Legal Proceedings", "See Note 9, Commitments and Contingencies."},
+				{"II", "1A", "Risk Factors", "There have been no material changes to our risk factors."},
+				{"II", "6", "Exhibits", "31.1 Certification of the Chief Executive Officer."},
+			},
+		},
+		{
+			name: "10-K without part headings",
+			text: tenKText,
+			want: []want{
+				{"", "1", "Business", "We make things."},
+				{"", "1A", "Risk Factors", "Things may break."},
+				{"", "7", "Management's Discussion and Analysis", "Sales rose."},
+			},
+		},
+		{
+			name: "headings without a period",
+			text: plainHeadingsText,
+			want: []want{
+				{"", "1", "Business", "We make things."},
+				{"", "1A", "RISK FACTORS", "Things may break."},
+				{"", "4", "Controls and Procedures", "Controls are effective."},
+				{"", "7", "MANAGEMENT'S DISCUSSION AND ANALYSIS", "Sales rose."},
+				{"", "10", "Directors, Executive Officers and Corporate Governance", "See the proxy statement."},
+			},
+		},
+		{
+			name: "no items",
+			text: "Exhibit 99.1\nPress release.\n",
+			want: []want{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractSections(tt.text)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d sections, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				s := got[i]
+				if s.Part != w.part || s.Item != w.item || s.Title != w.title || s.Text != w.text {
+					t.Errorf("section %d = {%q %q %q %q}, want {%q %q %q %q}",
+						i, s.Part, s.Item, s.Title, s.Text, w.part, w.item, w.title, w.text)
+				}
+				if heading := strings.ToLower(tt.text[s.Offsets.Start:]); !strings.HasPrefix(heading, "item "+strings.ToLower(w.item)) {
+					t.Errorf("section %d starts at %q", i, tt.text[s.Offsets.Start:s.Offsets.End])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterSections(t *testing.T) {
+	sections := ExtractSections(tenQText)
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"II-1A", []string{"II-1A"}},
+		{"1", []string{"I-1", "II-1"}},
+		{"i-2, ii-6", []string{"I-2", "II-6"}},
+		{"7", []string{}},
+		{"all", []string{"I-1", "I-2", "II-1", "II-1A", "II-6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			kept := filterSections(sections, tt.list)
+			got := make([]string, 0, len(kept))
+			for _, s := range kept {
+				got = append(got, sectionKey(s.Part, s.Item))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("filterSections(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package types
+
+import "strings"
+
+// A StandardConcept is one line of the standardized statements, resolved
+// from the first concept of its fallback chain reported for each period
+type StandardConcept struct {
+	Name      string
+	Statement string
+	Concepts  []string // us-gaap concepts in order of preference
+}
+
+// The standardized statements. Companies tag the same line with different
+// concepts, and change concepts over time (SalesRevenueNet gave way to
+// RevenueFromContractWithCustomerExcludingAssessedTax with ASC 606), so each
+// line lists the concepts it may be reported under, most specific first.
+var standardConcepts = []StandardConcept{
+	{"TotalRevenue", IncomeStatement, []string{
+		"Revenues",
+		"RevenueFromContractWithCustomerExcludingAssessedTax",
+		"RevenueFromContractWithCustomerIncludingAssessedTax",
+		"SalesRevenueNet",
+		"SalesRevenueGoodsNet",
+		"SalesRevenueServicesNet",
+		"RevenuesNetOfInterestExpense",
+	}},
+	{"COGS", IncomeStatement, []string{
+		"CostOfRevenue",
+		"CostOfGoodsAndServicesSold",
+		"CostOfGoodsSold",
+		"CostOfServices",
+		"CostOfGoodsAndServiceExcludingDepreciationDepletionAndAmortization",
+	}},
+	{"GrossProfit", IncomeStatement, []string{"GrossProfit"}},
+	{"SG&A", IncomeStatement, []string{"SellingGeneralAndAdministrativeExpense"}},
+	{"R&D", IncomeStatement, []string{
+		"ResearchAndDevelopmentExpense",
+		"ResearchAndDevelopmentExpenseExcludingAcquiredInProcessCost",
+	}},
+	{"OperatingExpenses", IncomeStatement, []string{"OperatingExpenses"}},
+	{"EBIT", IncomeStatement, []string{"OperatingIncomeLoss"}},
+	{"InterestExpense", IncomeStatement, []string{
+		"InterestExpense",
+		"InterestExpenseNonoperating",
+		"InterestExpenseDebt",
+	}},
+	{"PretaxIncome", IncomeStatement, []string{
+		"IncomeLossFromContinuingOperationsBeforeIncomeTaxesExtraordinaryItemsNoncontrollingInterest",
+		"IncomeLossFromContinuingOperationsBeforeIncomeTaxesMinorityInterestAndIncomeLossFromEquityMethodInvestments",
+	}},
+	{"IncomeTax", IncomeStatement, []string{"IncomeTaxExpenseBenefit"}},
+	{"NetIncome", IncomeStatement, []string{
+		"NetIncomeLoss",
+		"ProfitLoss",
+		"NetIncomeLossAvailableToCommonStockholdersBasic",
+	}},
+	{"EPSDiluted", IncomeStatement, []string{
+		"EarningsPerShareDiluted",
+		"EarningsPerShareBasicAndDiluted",
+	}},
+	{"DilutedShares", IncomeStatement, []string{
+		"WeightedAverageNumberOfDilutedSharesOutstanding",
+		"WeightedAverageNumberOfShareOutstandingBasicAndDiluted",
+	}},
+	{"DividendsPerShare", IncomeStatement, []string{
+		"CommonStockDividendsPerShareDeclared",
+		"CommonStockDividendsPerShareCashPaid",
+	}},
+
+	{"CashAndEquivalents", BalanceSheet, []string{
+		"CashAndCashEquivalentsAtCarryingValue",
+		"CashCashEquivalentsRestrictedCashAndRestrictedCashEquivalents",
+		"Cash",
+	}},
+	{"ShortTermInvestments", BalanceSheet, []string{
+		"ShortTermInvestments",
+		"MarketableSecuritiesCurrent",
+		"AvailableForSaleSecuritiesDebtSecuritiesCurrent",
+	}},
+	{"AccountsReceivable", BalanceSheet, []string{
+		"AccountsReceivableNetCurrent",
+		"ReceivablesNetCurrent",
+	}},
+	{"Inventory", BalanceSheet, []string{"InventoryNet"}},
+	{"TotalCurrentAssets", BalanceSheet, []string{"AssetsCurrent"}},
+	{"PP&E", BalanceSheet, []string{"PropertyPlantAndEquipmentNet"}},
+	{"Goodwill", BalanceSheet, []string{"Goodwill"}},
+	{"TotalAssets", BalanceSheet, []string{"Assets"}},
+	{"AccountsPayable", BalanceSheet, []string{
+		"AccountsPayableCurrent",
+		"AccountsPayableAndAccruedLiabilitiesCurrent",
+	}},
+	{"ShortTermDebt", BalanceSheet, []string{
+		"DebtCurrent",
+		"LongTermDebtCurrent",
+		"ShortTermBorrowings",
+	}},
+	{"TotalCurrentLiabilities", BalanceSheet, []string{"LiabilitiesCurrent"}},
+	{"LongTermDebt", BalanceSheet, []string{
+		"LongTermDebtNoncurrent",
+		"LongTermDebt",
+	}},
+	{"TotalLiabilities", BalanceSheet, []string{"Liabilities"}},
+	{"RetainedEarnings", BalanceSheet, []string{"RetainedEarningsAccumulatedDeficit"}},
+	{"TotalEquity", BalanceSheet, []string{
+		"StockholdersEquity",
+		"StockholdersEquityIncludingPortionAttributableToNoncontrollingInterest",
+	}},
+	{"TotalLiabilitiesAndEquity", BalanceSheet, []string{"LiabilitiesAndStockholdersEquity"}},
+
+	{"OperatingCashFlow", CashFlowStatement, []string{
+		"NetCashProvidedByUsedInOperatingActivities",
+		"NetCashProvidedByUsedInOperatingActivitiesContinuingOperations",
+	}},
+	{"DepreciationAndAmortization", CashFlowStatement, []string{
+		"DepreciationDepletionAndAmortization",
+		"DepreciationAndAmortization",
+		"DepreciationAmortizationAndAccretionNet",
+		"Depreciation",
+	}},
+	{"ShareBasedCompensation", CashFlowStatement, []string{
+		"ShareBasedCompensation",
+		"AllocatedShareBasedCompensationExpense",
+	}},
+	{"CapitalExpenditures", CashFlowStatement, []string{
+		"PaymentsToAcquirePropertyPlantAndEquipment",
+		"PaymentsToAcquireProductiveAssets",
+	}},
+	{"InvestingCashFlow", CashFlowStatement, []string{
+		"NetCashProvidedByUsedInInvestingActivities",
+		"NetCashProvidedByUsedInInvestingActivitiesContinuingOperations",
+	}},
+	{"DividendsPaid", CashFlowStatement, []string{
+		"PaymentsOfDividends",
+		"PaymentsOfDividendsCommonStock",
+	}},
+	{"ShareRepurchases", CashFlowStatement, []string{"PaymentsForRepurchaseOfCommonStock"}},
+	{"FinancingCashFlow", CashFlowStatement, []string{
+		"NetCashProvidedByUsedInFinancingActivities",
+		"NetCashProvidedByUsedInFinancingActivitiesContinuingOperations",
+	}},
+}
+
+// A StandardLine is a line of the standardized statements with exactly one
+// value per column of the report. The Concept of each value records which
+// concept of the fallback chain it was read from.
+type StandardLine struct {
+	Name      string
+	Statement string
+	Unit      string         `json:",omitempty"` // unit of the first concept found
+	Data      []*ReportValue // one value per column of the report, nil where not reported
+	TTM       []*ReportValue `json:",omitempty"`
+}
+
+// Returns the concepts the values of the line were read from, oldest first
+func (s *StandardLine) concepts() []string {
+	used := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, values := range [][]*ReportValue{s.Data, s.TTM} {
+		for _, v := range values {
+			if v != nil && !seen[v.Concept] {
+				seen[v.Concept] = true
+				used = append(used, v.Concept)
+			}
+		}
+	}
+	return used
+}
+
+// Resolves the standardized statements from the company facts. Each period
+// takes its value from the first concept of the chain reported for it, so a
+// company that switched concepts still yields a continuous line.
+func (g *GetConfig) standardize(f *CompanyFacts) []StandardLine {
+	lines := make([]StandardLine, 0, len(standardConcepts))
+	for _, sc := range standardConcepts {
+		line := StandardLine{
+			Name:      sc.Name,
+			Statement: sc.Statement,
+			Data:      make([]*ReportValue, len(g.periods)),
+		}
+		var ttm []*ReportValue
+		for _, concept := range sc.Concepts {
+			factData, ok := f.Facts.Data[concept]
+			if !ok {
+				continue
+			}
+			entries, unit := factData.Units.primary()
+			if line.Unit == "" {
+				line.Unit = unit
+			}
+			fill(line.Data, g.findRelevantUnitEntries(&entries, unit == "USD"), concept)
+			if unit != "USD" {
+				continue
+			}
+			if t := g.trailingTwelveMonths(entries); t != nil {
+				if ttm == nil {
+					ttm = make([]*ReportValue, len(g.periods))
+				}
+				fill(ttm, t, concept)
+			}
+		}
+		line.TTM = ttm
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Fills the empty columns of dst with the values of src read from concept
+func fill(dst []*ReportValue, src []*ReportValue, concept string) {
+	for i, v := range src {
+		if dst[i] == nil && v != nil {
+			v.Concept = concept
+			dst[i] = v
+		}
+	}
+}
+
+// Returns the rows of the standardized statements for the pivoted report
+func standardRows(lines []StandardLine) [][]string {
+	rows := make([][]string, 0, len(lines))
+	for _, s := range lines {
+		concepts := strings.Join(s.concepts(), "|")
+		rows = append(rows, valueRow([]string{"Standardized/" + s.Statement, s.Name, concepts}, s.Data))
+		if s.TTM != nil {
+			rows = append(rows, valueRow([]string{"Standardized/" + s.Statement, s.Name + " (TTM)", concepts}, s.TTM))
+		}
+	}
+	return rows
+}
@@ -0,0 +1,405 @@
+package types
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Statement kinds a FilingSummary report can be classified as
+const (
+	BalanceSheet      = "balance sheet"
+	IncomeStatement   = "income statement"
+	CashFlowStatement = "cash flow"
+	EquityStatement   = "equity"
+)
+
+// The FilingSummary.xml of a filing lists the rendered "R" pages, one per
+// statement, disclosure and detail table
+type filingSummary struct {
+	Reports []summaryReport `xml:"MyReports>Report"`
+}
+
+type summaryReport struct {
+	HtmlFileName string `xml:"HtmlFileName"`
+	LongName     string `xml:"LongName"`
+	ShortName    string `xml:"ShortName"`
+	MenuCategory string `xml:"MenuCategory"`
+	Role         string `xml:"Role"`
+}
+
+// A Statement is a financial statement as the company presented it, with its
+// line items in order, subtotals included
+type Statement struct {
+	Kind string `json:"kind"`
+	Role string `json:"role"`
+	File string `json:"file"`
+	FinancialTable
+}
+
+var (
+	// "onclick="top.Show.showAR( this, 'defref_us-gaap_Revenues', window )"
+	defref = regexp.MustCompile(`defref_([A-Za-z0-9-]+)_(\w+)`)
+	// "$ in Millions", "shares in Thousands"
+	dollarScale = regexp.MustCompile(`(?i)\$\s+in\s+(thousands|millions|billions)`)
+	shareScale  = regexp.MustCompile(`(?i)shares\s+in\s+(thousands|millions|billions)`)
+)
+
+// Returns the kind of statement a report is, or "" for disclosures, details
+// and parenthetical statements
+func (r *summaryReport) kind() string {
+	isStatement := strings.EqualFold(r.MenuCategory, "Statements") || strings.Contains(r.LongName, " - Statement - ")
+	name := strings.ToLower(r.ShortName)
+	if !isStatement || strings.Contains(name, "parenthetical") {
+		return ""
+	}
+	switch {
+	case strings.Contains(name, "cash flow"):
+		return CashFlowStatement
+	case strings.Contains(name, "balance sheet") || strings.Contains(name, "financial position") ||
+		strings.Contains(name, "financial condition"):
+		return BalanceSheet
+	case strings.Contains(name, "equity") || strings.Contains(name, "stockholders") ||
+		strings.Contains(name, "shareholders") || strings.Contains(name, "partners"):
+		return EquityStatement
+	case strings.Contains(name, "operations") || strings.Contains(name, "income") ||
+		strings.Contains(name, "earnings"):
+		// A statement of comprehensive income alone is not the income statement
+		if strings.Contains(name, "comprehensive") && !strings.Contains(name, "operations") &&
+			!strings.Contains(name, "and comprehensive") {
+			return ""
+		}
+		return IncomeStatement
+	}
+	return ""
+}
+
+// Returns the statements listed in a FilingSummary.xml, in filing order.
+// Filings from before 2010 rendered R pages as XML and are not supported.
+func parseFilingSummary(b []byte) ([]summaryReport, error) {
+	var fs filingSummary
+	err := xml.Unmarshal(b, &fs)
+	if err != nil {
+		return nil, err
+	}
+	statements := make([]summaryReport, 0)
+	for _, r := range fs.Reports {
+		if r.kind() != "" && strings.HasSuffix(r.HtmlFileName, ".htm") {
+			statements = append(statements, r)
+		}
+	}
+	return statements, nil
+}
+
+// Parses a rendered R page into a statement. The header rows hold the period
+// columns ("12 Months Ended" above "Sep. 30, 2023") and the scale of the
+// amounts; each following row is one line item.
+func parseReportPage(doc *html.Node) (*FinancialTable, error) {
+	var table *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if table != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "table" && strings.Contains(attr(n, "class"), "report") {
+			table = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if table == nil {
+		return nil, fmt.Errorf("no report table found")
+	}
+
+	headers, rows := reportRows(table)
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("report table has no header")
+	}
+	t := &FinancialTable{Caption: headers[0][0], Scale: 1}
+	dollars, shares := 1.0, 1.0
+	if m := dollarScale.FindStringSubmatch(t.Caption); m != nil {
+		dollars = scales[strings.ToLower(m[1])]
+		t.Scale = dollars
+	}
+	if m := shareScale.FindStringSubmatch(t.Caption); m != nil {
+		shares = scales[strings.ToLower(m[1])]
+	}
+	// Merge the header rows column by column, skipping the caption column
+	width := len(headers[0])
+	for _, h := range headers {
+		if len(h) > width {
+			width = len(h)
+		}
+	}
+	t.Columns = make([]string, width-1)
+	for _, h := range headers {
+		for i := 1; i < len(h); i++ {
+			if h[i] != "" && !strings.Contains(t.Columns[i-1], h[i]) {
+				t.Columns[i-1] = strings.TrimSpace(t.Columns[i-1] + " " + h[i])
+			}
+		}
+	}
+
+	for _, r := range rows {
+		row := TableRow{Label: r.label, Concept: r.concept, Cells: make([]TableCell, len(t.Columns))}
+		lower := strings.ToLower(r.label + " " + r.concept)
+		perShare := strings.Contains(lower, "per share") || strings.Contains(lower, "pershare")
+		isShares := strings.Contains(lower, "shares") || strings.Contains(lower, "numberof")
+		for i, text := range r.cells {
+			if i >= len(row.Cells) {
+				break
+			}
+			cell := TableCell{Text: text}
+			if n, unit, ok := parseNumber(text); ok && text != "" {
+				switch {
+				case unit == "%" || perShare:
+				case isShares && unit != "USD":
+					n *= shares
+				default:
+					n *= dollars
+				}
+				cell.Value = &n
+				cell.Unit = unit
+			}
+			row.Cells[i] = cell
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// A line item row of an R page
+type reportRow struct {
+	label, concept string
+	cells          []string
+}
+
+// Splits an R page table into its header rows, expanded to one string per
+// column, and its line item rows
+func reportRows(table *html.Node) ([][]string, []reportRow) {
+	headers := make([][]string, 0)
+	rows := make([]reportRow, 0)
+	// Columns still covered by a rowspan from an earlier header row
+	spanned := make(map[int]int)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type != html.ElementNode || n.Data != "tr" {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+			return
+		}
+		isHeader := false
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "th" {
+				isHeader = true
+			}
+		}
+		if isHeader {
+			h := make([]string, 0)
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != html.ElementNode || c.Data != "th" {
+					continue
+				}
+				for spanned[len(h)] > 0 {
+					spanned[len(h)]--
+					h = append(h, "")
+				}
+				colspan, err := strconv.Atoi(attr(c, "colspan"))
+				if err != nil || colspan < 1 {
+					colspan = 1
+				}
+				rowspan, err := strconv.Atoi(attr(c, "rowspan"))
+				if err != nil || rowspan < 1 {
+					rowspan = 1
+				}
+				text := nodeText(c)
+				for i := 0; i < colspan; i++ {
+					if rowspan > 1 {
+						spanned[len(h)] = rowspan - 1
+					}
+					h = append(h, text)
+				}
+			}
+			headers = append(headers, h)
+			return
+		}
+		r := reportRow{}
+		first := true
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.Data != "td" {
+				continue
+			}
+			if first {
+				r.label = nodeText(c)
+				r.concept = rowConcept(c)
+				first = false
+				continue
+			}
+			r.cells = append(r.cells, nodeText(c))
+		}
+		if r.label != "" {
+			rows = append(rows, r)
+		}
+	}
+	walk(table)
+	return headers, rows
+}
+
+// Returns the concept QName referenced by the label cell's defref link
+func rowConcept(td *html.Node) string {
+	var concept string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if concept != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if m := defref.FindStringSubmatch(attr(n, "onclick")); m != nil {
+				concept = m[1] + ":" + m[2]
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(td)
+	return concept
+}
+
+// Holds the options for the statements subcommand
+type StatementsConfig struct {
+	Ticker    string
+	CIK       string
+	Accession string
+	Dir       string // directory holding FilingSummary.xml and the R pages
+	Format    string // json or csv
+}
+
+// Downloads FilingSummary.xml and the R pages of the statements it lists
+// into app/<ticker>/<accession>/ and returns the directory
+func (s *StatementsConfig) download(c *ClientConfig) (string, error) {
+	if s.CIK == "" {
+		cik, ok := c.checkCompanyTickers()[strings.ToUpper(s.Ticker)]
+		if !ok {
+			return "", fmt.Errorf("ticker %s not found", s.Ticker)
+		}
+		s.CIK = strconv.Itoa(cik)
+	}
+	cik, err := cikNumber(s.CIK)
+	if err != nil {
+		return "", err
+	}
+	folder := filingUrl(strconv.Itoa(cik), s.Accession, "")
+	dir := "app/" + s.Ticker + "/" + s.Accession + "/"
+	err = downloadFiles([]string{folder + "FilingSummary.xml"}, dir, c)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "FilingSummary.xml"))
+	if err != nil {
+		return "", err
+	}
+	reports, err := parseFilingSummary(b)
+	if err != nil {
+		return "", err
+	}
+	urls := make([]string, 0, len(reports))
+	for _, r := range reports {
+		urls = append(urls, folder+r.HtmlFileName)
+	}
+	return dir, downloadFiles(urls, dir, c)
+}
+
+// Reads the statements of a filing from a directory holding its
+// FilingSummary.xml and R pages
+func loadStatements(dir string) ([]Statement, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "FilingSummary.xml"))
+	if err != nil {
+		return nil, err
+	}
+	reports, err := parseFilingSummary(b)
+	if err != nil {
+		return nil, err
+	}
+	statements := make([]Statement, 0, len(reports))
+	for _, r := range reports {
+		f, err := os.Open(filepath.Join(dir, r.HtmlFileName))
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseFilingHTML(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", r.HtmlFileName, err)
+		}
+		t, err := parseReportPage(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", r.HtmlFileName, err)
+		}
+		statements = append(statements, Statement{Kind: r.kind(), Role: r.Role, File: r.HtmlFileName, FinancialTable: *t})
+	}
+	return statements, nil
+}
+
+func (s *StatementsConfig) HandleStatements() {
+	dir := s.Dir
+	if dir == "" {
+		if s.Accession == "" || (s.Ticker == "" && s.CIK == "") {
+			fmt.Println("Error: expected a ticker and -accession, or -dir. Exiting...")
+			os.Exit(1)
+		}
+		var err error
+		dir, err = s.download(checkConfig())
+		if err != nil {
+			fmt.Printf("Error: could not download statements! (%v)\n", err)
+			os.Exit(1)
+		}
+	}
+	statements, err := loadStatements(dir)
+	if err != nil {
+		fmt.Printf("Error: could not read statements! (%v)\n", err)
+		os.Exit(1)
+	}
+	if len(statements) == 0 {
+		fmt.Printf("Error: no financial statements found in %s!\n", dir)
+		os.Exit(1)
+	}
+
+	if s.Format == "csv" {
+		for i, st := range statements {
+			out := filepath.Join(dir, fmt.Sprintf("statement_%02d_%s.csv", i+1, strings.ReplaceAll(st.Kind, " ", "_")))
+			err = writeTableCSV(out, &st.FinancialTable)
+			if err != nil {
+				fmt.Printf("Error: could not write file! (%v)\n", err)
+				os.Exit(1)
+			}
+		}
+	} else {
+		b, err := json.MarshalIndent(statements, "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal statements! (%v)\n", err)
+			os.Exit(1)
+		}
+		err = os.WriteFile(filepath.Join(dir, "statements.json"), b, 0666)
+		if err != nil {
+			fmt.Printf("Error: could not write file! (%v)\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, st := range statements {
+		fmt.Printf("%s\t%s\t%d line items\n", st.File, st.Kind, len(st.Rows))
+	}
+	fmt.Printf("Your statements are located in %s\n", dir)
+}
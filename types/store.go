@@ -0,0 +1,266 @@
+package types
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go driver, no cgo required
+)
+
+// Returned by LoadCompanyFacts when a company has never been synced
+var errNotInStore = errors.New("company not found in store")
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS companies (
+	cik       INTEGER PRIMARY KEY,
+	name      TEXT NOT NULL,
+	synced_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS concepts (
+	taxonomy TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	label    TEXT NOT NULL,
+	PRIMARY KEY (taxonomy, name)
+);
+CREATE TABLE IF NOT EXISTS filings (
+	accession TEXT NOT NULL,
+	cik       INTEGER NOT NULL REFERENCES companies(cik),
+	form      TEXT NOT NULL,
+	filed     TEXT NOT NULL,
+	fy        INTEGER NOT NULL,
+	fp        TEXT NOT NULL,
+	PRIMARY KEY (cik, accession)
+);
+CREATE TABLE IF NOT EXISTS facts (
+	cik       INTEGER NOT NULL REFERENCES companies(cik),
+	taxonomy  TEXT NOT NULL,
+	concept   TEXT NOT NULL,
+	unit      TEXT NOT NULL,
+	accession TEXT NOT NULL,
+	start     TEXT NOT NULL DEFAULT '',
+	end       TEXT NOT NULL,
+	val       NUMERIC NOT NULL,
+	fy        INTEGER NOT NULL,
+	fp        TEXT NOT NULL,
+	form      TEXT NOT NULL,
+	filed     TEXT NOT NULL,
+	frame     TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (cik, accession, taxonomy, concept, unit, start, end)
+);
+CREATE INDEX IF NOT EXISTS facts_by_company ON facts (cik, taxonomy, concept);
+`
+
+// Store persists CompanyFacts in a local SQLite database so facts can be
+// queried with SQL and reports can be assembled without hitting the SEC API.
+type Store struct {
+	db *sql.DB
+}
+
+// Opens (and creates, if needed) the SQLite database at the given path
+func OpenStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer, so don't let database/sql pool
+	// connections that would fight over the lock
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(storeSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Upserts every fact in cf into the store. Facts are keyed by CIK, accession,
+// concept, unit and period, so re-syncing a company only touches facts that
+// are new or whose value changed. Returns the number of facts written.
+func (s *Store) SyncCompanyFacts(cf *CompanyFacts) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO companies (cik, name, synced_at) VALUES (?, ?, ?)
+		ON CONFLICT (cik) DO UPDATE SET name = excluded.name, synced_at = excluded.synced_at`,
+		cf.Cik, cf.EntityName, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	concept, err := tx.Prepare(`INSERT INTO concepts (taxonomy, name, label) VALUES (?, ?, ?)
+		ON CONFLICT (taxonomy, name) DO UPDATE SET label = excluded.label`)
+	if err != nil {
+		return 0, err
+	}
+	defer concept.Close()
+	filing, err := tx.Prepare(`INSERT INTO filings (accession, cik, form, filed, fy, fp) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cik, accession) DO NOTHING`)
+	if err != nil {
+		return 0, err
+	}
+	defer filing.Close()
+	fact, err := tx.Prepare(`INSERT INTO facts (cik, taxonomy, concept, unit, accession, start, end, val, fy, fp, form, filed, frame)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cik, accession, taxonomy, concept, unit, start, end) DO UPDATE SET
+			val = excluded.val, fy = excluded.fy, fp = excluded.fp, form = excluded.form,
+			filed = excluded.filed, frame = excluded.frame
+		WHERE val IS NOT excluded.val OR frame IS NOT excluded.frame`)
+	if err != nil {
+		return 0, err
+	}
+	defer fact.Close()
+
+	written := 0
+	for taxonomy, data := range cf.taxonomies() {
+		for name, fd := range data {
+			_, err = concept.Exec(taxonomy, name, fd.Label)
+			if err != nil {
+				return 0, err
+			}
+			for unit, entries := range fd.Units.byUnit() {
+				for _, e := range entries {
+					_, err = filing.Exec(e.Accession, cf.Cik, e.Form, e.Filed, e.FiscalYear, e.ForPeriod)
+					if err != nil {
+						return 0, err
+					}
+					res, err := fact.Exec(cf.Cik, taxonomy, name, unit, e.Accession, e.PeriodStart, e.PeriodEnd,
+						e.Value.String(), e.FiscalYear, e.ForPeriod, e.Form, e.Filed, e.Frame)
+					if err != nil {
+						return 0, err
+					}
+					n, err := res.RowsAffected()
+					if err != nil {
+						return 0, err
+					}
+					written += int(n)
+				}
+			}
+		}
+	}
+	return written, tx.Commit()
+}
+
+// Returns the CIKs of every company synced to the store
+func (s *Store) Companies() ([]int, error) {
+	rows, err := s.db.Query(`SELECT cik FROM companies ORDER BY cik`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ciks := make([]int, 0)
+	for rows.Next() {
+		var cik int
+		err = rows.Scan(&cik)
+		if err != nil {
+			return nil, err
+		}
+		ciks = append(ciks, cik)
+	}
+	return ciks, rows.Err()
+}
+
+// Rebuilds the CompanyFacts for a CIK from the store. Returns errNotInStore if
+// the company has not been synced yet.
+func (s *Store) LoadCompanyFacts(cik int) (*CompanyFacts, error) {
+	cf := &CompanyFacts{Cik: cik}
+	err := s.db.QueryRow(`SELECT name FROM companies WHERE cik = ?`, cik).Scan(&cf.EntityName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotInStore
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT f.taxonomy, f.concept, c.label, f.unit, f.accession, f.start, f.end,
+			CAST(f.val AS TEXT), f.fy, f.fp, f.form, f.filed, f.frame
+		FROM facts f JOIN concepts c ON c.taxonomy = f.taxonomy AND c.name = f.concept
+		WHERE f.cik = ?
+		ORDER BY f.taxonomy, f.concept, f.unit, f.end, f.filed`, cik)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cf.Facts.Data = make(map[string]FactData)
+	for rows.Next() {
+		var taxonomy, concept, label, unit, val string
+		var e UnitEntry
+		err = rows.Scan(&taxonomy, &concept, &label, &unit, &e.Accession, &e.PeriodStart, &e.PeriodEnd,
+			&val, &e.FiscalYear, &e.ForPeriod, &e.Form, &e.Filed, &e.Frame)
+		if err != nil {
+			return nil, err
+		}
+		e.Value = json.Number(val)
+		data := cf.taxonomy(taxonomy)
+		fd := data[concept]
+		fd.Label = label
+		fd.Units.add(unit, e)
+		data[concept] = fd
+	}
+	return cf, rows.Err()
+}
+
+// Loads the company facts for the configured CIK, preferring the local store
+// when -store is set. Companies missing from the store, or any company when
+// -sync is set, are fetched from the SEC API and upserted before loading.
+func (g *GetConfig) loadCompanyFacts(c *ClientConfig, url string) *CompanyFacts {
+	cf, err := g.companyFacts(c, url)
+	if err != nil {
+		fmt.Printf("Error: %v!\n", err)
+		os.Exit(1)
+	}
+	return cf
+}
+
+// Loads the company facts like loadCompanyFacts, returning any error so a
+// command working through several companies can carry on with the next
+func (g *GetConfig) companyFacts(c *ClientConfig, url string) (*CompanyFacts, error) {
+	if g.Store == "" {
+		return c.fetchCompanyFacts(url)
+	}
+	s, err := OpenStore(g.Store)
+	if err != nil {
+		return nil, fmt.Errorf("could not open store %s (%v)", g.Store, err)
+	}
+	defer s.Close()
+	cik, err := cikNumber(g.CIK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIK %s (%v)", g.CIK, err)
+	}
+	if !g.Sync {
+		cf, err := s.LoadCompanyFacts(cik)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Loaded company facts for %s from %s\n", cf.EntityName, g.Store)
+			return cf, nil
+		}
+		if !errors.Is(err, errNotInStore) {
+			return nil, fmt.Errorf("could not read store %s (%v)", g.Store, err)
+		}
+		fmt.Fprintln(os.Stderr, "Company not found in store, requesting company facts...")
+	}
+	cf, err := c.fetchCompanyFacts(url)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.SyncCompanyFacts(cf)
+	if err != nil {
+		return nil, fmt.Errorf("could not sync company facts to %s (%v)", g.Store, err)
+	}
+	fmt.Fprintf(os.Stderr, "Synced %d new or changed facts to %s\n", n, g.Store)
+	return cf, nil
+}
+
+// Converts a CIK string such as "CIK0000320193" or "320193" to its number
+func cikNumber(cik string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(strings.ToUpper(cik), "CIK"))
+}
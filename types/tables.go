@@ -0,0 +1,508 @@
+package types
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A FinancialTable is an HTML table from a filing with its header rows merged
+// and its numbers parsed
+type FinancialTable struct {
+	Caption   string     `json:"caption"`
+	Scale     float64    `json:"scale"` // 1, 1e3, 1e6 or 1e9 from hints like "(in millions)"
+	Columns   []string   `json:"columns"`
+	Rows      []TableRow `json:"rows"`
+	Footnotes []string   `json:"footnotes,omitempty"`
+}
+
+type TableRow struct {
+	Label     string      `json:"label"`
+	Concept   string      `json:"concept,omitempty"` // XBRL concept, when known
+	Cells     []TableCell `json:"cells"`             // one per column, empty cells included
+	Footnotes []string    `json:"footnotes,omitempty"`
+}
+
+type TableCell struct {
+	Text      string   `json:"text"`
+	Value     *float64 `json:"value,omitempty"` // scaled; nil if the cell is not a number
+	Unit      string   `json:"unit,omitempty"`  // "USD" or "%"
+	Footnotes []string `json:"footnotes,omitempty"`
+}
+
+var (
+	scaleHint = regexp.MustCompile(`(?i)\bin\s+(thousands|millions|billions)\b`)
+	// A number as printed in a filing: $1,234.5, (45), 42.5%, — ...
+	numberCell = regexp.MustCompile(`^\$?\s*\(?\s*\$?\s*(\d[\d,]*(?:\.\d+)?|\.\d+)\s*\)?\s*%?\)?$`)
+	dashCell   = regexp.MustCompile(`^\$?\s*[-–—]+\s*%?$`)
+	yearCell   = regexp.MustCompile(`^(19|20)\d\d$`)
+	// Trailing footnote markers such as "(1)", "(a)" or "*"
+	footnoteMarker = regexp.MustCompile(`\s*(\((?:\d{1,2}|[a-z])\)|\*+)$`)
+	footnoteLine   = regexp.MustCompile(`^(\((?:\d{1,2}|[a-z])\)|\*+)\s+\S`)
+)
+
+var scales = map[string]float64{"thousands": 1e3, "millions": 1e6, "billions": 1e9}
+
+// A cell of the raw table grid
+type gridCell struct {
+	col       int // first grid column covered by the cell
+	span      int
+	text      string
+	footnotes []string
+}
+
+// Returns the footnote markers of a cell: the text of <sup> elements and
+// trailing markers such as "(1)". The markers are removed from the text.
+func cellText(n *html.Node) (string, []string) {
+	notes := make([]string, 0)
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if hidden(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "sup" {
+			if mark := strings.Trim(nodeText(n), "() "); mark != "" {
+				notes = append(notes, mark)
+			}
+			return
+		}
+		if n.Type == html.ElementNode && (n.Data == "br" || n.Data == "p" || n.Data == "div") {
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	text := strings.TrimSpace(spaceRun.ReplaceAllString(b.String(), " "))
+	// Numbers like "(45)" are values, not footnote markers
+	for !numberCell.MatchString(text) {
+		m := footnoteMarker.FindStringSubmatchIndex(text)
+		if m == nil || m[0] == 0 {
+			break
+		}
+		notes = append(notes, strings.Trim(text[m[2]:m[3]], "()"))
+		text = strings.TrimSpace(text[:m[0]])
+	}
+	return text, notes
+}
+
+// Returns the table as a grid of rows, each cell placed at its column
+func tableGrid(table *html.Node) [][]gridCell {
+	grid := make([][]gridCell, 0)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if hidden(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "table" && n != table {
+			return // nested tables are extracted on their own
+		}
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			row := make([]gridCell, 0)
+			col := 0
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") || hidden(c) {
+					continue
+				}
+				span, err := strconv.Atoi(attr(c, "colspan"))
+				if err != nil || span < 1 {
+					span = 1
+				}
+				text, notes := cellText(c)
+				row = append(row, gridCell{col: col, span: span, text: text, footnotes: notes})
+				col += span
+			}
+			grid = append(grid, row)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(table)
+	return grid
+}
+
+// A value cell of a data row after "$", ")" and "%" cells have been merged
+type valueCell struct {
+	col       int
+	text      string
+	footnotes []string
+}
+
+// Merges the cells following the label into values, each placed at the grid
+// column of its number
+func rowValues(row []gridCell) []valueCell {
+	values := make([]valueCell, 0)
+	pending := ""
+	for _, c := range row[1:] {
+		switch {
+		case c.text == "":
+			continue
+		case c.text == "$" || c.text == "(" || c.text == "$(" || c.text == "($":
+			pending += c.text
+			continue
+		case (c.text == ")" || c.text == "%" || c.text == ")%" || c.text == "%)") && len(values) > 0:
+			values[len(values)-1].text += c.text
+			continue
+		}
+		values = append(values, valueCell{col: c.col, text: pending + c.text, footnotes: c.footnotes})
+		pending = ""
+	}
+	return values
+}
+
+// Parses a printed number. Parentheses mean negative, dashes mean zero.
+func parseNumber(text string) (float64, string, bool) {
+	unit := ""
+	if strings.Contains(text, "%") {
+		unit = "%"
+	} else if strings.Contains(text, "$") {
+		unit = "USD"
+	}
+	if dashCell.MatchString(text) {
+		return 0, unit, true
+	}
+	m := numberCell.FindStringSubmatch(text)
+	if m == nil {
+		return 0, "", false
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	if strings.Contains(text, "(") {
+		v = -v
+	}
+	return v, unit, true
+}
+
+// Reports whether the value looks like a financial amount rather than a page
+// number or a year
+func financialValue(text string) bool {
+	if yearCell.MatchString(text) {
+		return false
+	}
+	_, _, ok := parseNumber(text)
+	return ok && strings.ContainsAny(text, "$,.%()")
+}
+
+// Builds a FinancialTable from an HTML table. Returns nil for layout tables
+// without any financial amounts.
+func extractTable(table *html.Node, caption string, hints string) *FinancialTable {
+	grid := tableGrid(table)
+
+	// Header rows are the rows above the first row with a label and an amount
+	first := -1
+	for i, row := range grid {
+		if len(row) < 2 || row[0].text == "" {
+			continue
+		}
+		for _, v := range rowValues(row) {
+			if financialValue(v.text) {
+				first = i
+				break
+			}
+		}
+		if first >= 0 {
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	// Every grid column holding a value becomes a column of the table.
+	// Rows often place their amounts one grid column apart (e.g. percentages
+	// without a "$" cell), so neighbouring grid columns that never hold values
+	// in the same row are grouped into one column.
+	used := make([]map[int]bool, 0)
+	colSet := make(map[int]bool)
+	for _, row := range grid[first:] {
+		if len(row) < 2 {
+			continue
+		}
+		cols := make(map[int]bool)
+		for _, v := range rowValues(row) {
+			cols[v.col] = true
+			colSet[v.col] = true
+		}
+		used = append(used, cols)
+	}
+	sorted := make([]int, 0, len(colSet))
+	for c := range colSet {
+		sorted = append(sorted, c)
+	}
+	sort.Ints(sorted)
+	groups := make([][]int, 0)
+	for _, c := range sorted {
+		if len(groups) > 0 {
+			g := groups[len(groups)-1]
+			if c-g[len(g)-1] <= 1 && !sharesRow(used, g, c) {
+				groups[len(groups)-1] = append(g, c)
+				continue
+			}
+		}
+		groups = append(groups, []int{c})
+	}
+	cols := make([]int, 0, len(sorted))
+	index := make(map[int]int, len(sorted))
+	for i, g := range groups {
+		for _, c := range g {
+			cols = append(cols, c)
+			index[c] = i
+		}
+	}
+
+	t := &FinancialTable{Caption: caption, Scale: 1, Columns: mergeHeaders(grid[:first], groups)}
+	if m := scaleHint.FindStringSubmatch(hints + " " + headerText(grid[:first])); m != nil {
+		t.Scale = scales[strings.ToLower(m[1])]
+	}
+
+	for _, row := range grid[first:] {
+		if len(row) == 0 || (row[0].text == "" && len(rowValues(row)) == 0) {
+			continue
+		}
+		r := TableRow{Label: row[0].text, Cells: make([]TableCell, len(groups)), Footnotes: row[0].footnotes}
+		if len(row) > 1 {
+			for _, v := range rowValues(row) {
+				i := nearestColumn(index, cols, v.col)
+				cell := TableCell{Text: v.text, Footnotes: v.footnotes}
+				if n, unit, ok := parseNumber(v.text); ok {
+					// Percentages and per-share amounts are never scaled
+					if unit != "%" && !strings.Contains(strings.ToLower(r.Label), "per share") {
+						n *= t.Scale
+					}
+					cell.Value = &n
+					cell.Unit = unit
+				}
+				r.Cells[i] = cell
+			}
+		}
+		t.Rows = append(t.Rows, r)
+	}
+	return t
+}
+
+// Reports whether any row holds values in both column c and one of the
+// columns of group g
+func sharesRow(used []map[int]bool, g []int, c int) bool {
+	for _, cols := range used {
+		if !cols[c] {
+			continue
+		}
+		for _, gc := range g {
+			if cols[gc] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the table column for a grid column, snapping to the closest one
+// when a row is offset from the others
+func nearestColumn(index map[int]int, cols []int, col int) int {
+	if i, ok := index[col]; ok {
+		return i
+	}
+	best := 0
+	for i, c := range cols {
+		if math.Abs(float64(c-col)) < math.Abs(float64(cols[best]-col)) {
+			best = i
+		}
+	}
+	return index[cols[best]]
+}
+
+// Merges split header rows, e.g. "Year Ended December 31," above "2023",
+// into a single header per column. A header cell applies to every column
+// under its colspan.
+func mergeHeaders(rows [][]gridCell, groups [][]int) []string {
+	headers := make([]string, len(groups))
+	for _, row := range rows {
+		for _, c := range row {
+			if c.text == "" || c.col == 0 {
+				continue
+			}
+			for i, g := range groups {
+				// Grouped columns span the header cell's columns, or the
+				// amount sits right after a "$" cell covered by the header
+				covered := false
+				for _, col := range g {
+					if col >= c.col && col < c.col+c.span {
+						covered = true
+					}
+				}
+				if !covered && !(g[0] == c.col+c.span && len(g) == 1 && c.span == 1) {
+					continue
+				}
+				if strings.HasSuffix(headers[i], c.text) {
+					continue
+				}
+				if headers[i] != "" {
+					headers[i] += " "
+				}
+				headers[i] += c.text
+			}
+		}
+	}
+	return headers
+}
+
+// Joins the text of every header cell, including the top-left cell and rows
+// spanning the full width that mergeHeaders leaves out, where filings often
+// state the scale, e.g. "(In millions, except per share data)"
+func headerText(rows [][]gridCell) string {
+	parts := make([]string, 0)
+	for _, row := range rows {
+		for _, c := range row {
+			if c.text != "" {
+				parts = append(parts, c.text)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Extracts every financial table in a filing together with its caption: the
+// closest short paragraph above the table that is not a scale hint. Lines
+// starting with a footnote marker right below a table become its footnotes.
+func ExtractTables(doc *html.Node) []FinancialTable {
+	tables := make([]FinancialTable, 0)
+	recent := make([]string, 0) // text of the blocks preceding the next table
+	var current *FinancialTable
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if hidden(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "table" {
+			caption, hints := "", ""
+			for i := len(recent) - 1; i >= 0 && i >= len(recent)-4; i-- {
+				if scaleHint.MatchString(recent[i]) {
+					hints += " " + recent[i]
+					continue
+				}
+				if caption == "" && len(recent[i]) <= maxHeadingLength && !footnoteLine.MatchString(recent[i]) {
+					caption = recent[i]
+				}
+			}
+			current = extractTable(n, caption, hints)
+			if current != nil {
+				tables = append(tables, *current)
+				current = &tables[len(tables)-1]
+			}
+			recent = recent[:0]
+			return
+		}
+		if n.Type == html.ElementNode && blockElements[n.Data] && !hasBlockChild(n) {
+			text := nodeText(n)
+			if text == "" {
+				return
+			}
+			if current != nil && footnoteLine.MatchString(text) {
+				current.Footnotes = append(current.Footnotes, text)
+				return
+			}
+			current = nil
+			recent = append(recent, text)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return tables
+}
+
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (blockElements[c.Data] || hasBlockChild(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the tables as JSON, or as one CSV file per table, next to the source
+func (p *ParseConfig) writeTables(doc *html.Node) {
+	tables := ExtractTables(doc)
+	if len(tables) == 0 {
+		fmt.Printf("Error: no financial tables found in %s!\n", p.File)
+		os.Exit(1)
+	}
+	if p.Format == "csv" {
+		dir := p.outputBase() + "_tables/"
+		err := createDir(dir)
+		if err != nil {
+			fmt.Printf("Error: could not create '%s' directory! (%v)\n", dir, err)
+			os.Exit(1)
+		}
+		for i := range tables {
+			out := fmt.Sprintf("%stable_%03d.csv", dir, i+1)
+			err = writeTableCSV(out, &tables[i])
+			if err != nil {
+				fmt.Printf("Error: could not write file! (%v)\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Extracted %d table(s) from %s to %s\n", len(tables), p.File, dir)
+		return
+	}
+	b, err := json.MarshalIndent(tables, "", "	")
+	if err != nil {
+		fmt.Printf("Error: could not marshal tables! (%v)\n", err)
+		os.Exit(1)
+	}
+	out := p.outputBase() + ".tables.json"
+	err = os.WriteFile(out, b, 0666)
+	if err != nil {
+		fmt.Printf("Error: could not write file! (%v)\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Extracted %d table(s) from %s to %s\n", len(tables), p.File, out)
+}
+
+// Writes a table as CSV. The caption heads the label column; numbers are
+// written scaled, other cells as printed.
+func writeTableCSV(name string, t *FinancialTable) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write(append([]string{t.Caption}, t.Columns...))
+	for _, r := range t.Rows {
+		record := []string{r.Label}
+		for _, c := range r.Cells {
+			if c.Value != nil {
+				record = append(record, strconv.FormatFloat(*c.Value, 'f', -1, 64))
+			} else {
+				record = append(record, c.Text)
+			}
+		}
+		w.Write(record)
+	}
+	for _, note := range t.Footnotes {
+		w.Write([]string{note})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,189 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Relative tolerance of the integrity checks. Companies report rounded
+// figures, so totals rarely tie out to the dollar.
+const relTolerance = 0.001
+
+// A Discrepancy is an accounting identity that does not hold in one period
+// of a report
+type Discrepancy struct {
+	Check      string  `json:"check"`
+	Period     string  `json:"period"`
+	Expected   float64 `json:"expected"` // the right hand side of the identity
+	Actual     float64 `json:"actual"`   // the reported value
+	Difference float64 `json:"difference"`
+	Tolerance  float64 `json:"tolerance"`
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s: %s: expected %.0f, reported %.0f (off by %.0f, tolerance %.0f)",
+		d.Period, d.Check, d.Expected, d.Actual, d.Difference, d.Tolerance)
+}
+
+// Returns the value of a concept in a column of the report, from whichever
+// section it was assembled into
+func (r *FinancialStatement) conceptValue(concept string, col int) (*ReportValue, bool) {
+	for _, s := range r.Sections() {
+		for _, li := range *s.Items {
+			if li.Concept == concept && col < len(li.Data) && li.Data[col] != nil {
+				return li.Data[col], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Returns the first of the concepts reported in the column as a number
+func (r *FinancialStatement) number(col int, concepts ...string) (float64, bool) {
+	for _, c := range concepts {
+		if v, ok := r.conceptValue(c, col); ok {
+			f, err := strconv.ParseFloat(v.Value.String(), 64)
+			if err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Returns the value of a standardized line in the column as a number
+func (r *FinancialStatement) standardNumber(name string, col int) (float64, bool) {
+	for _, s := range r.Standardized {
+		if s.Name == name && col < len(s.Data) && s.Data[col] != nil {
+			f, err := strconv.ParseFloat(s.Data[col].Value.String(), 64)
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Total equity, noncontrolling interests included
+func (r *FinancialStatement) totalEquity(col int) (float64, bool) {
+	if v, ok := r.number(col, "StockholdersEquityIncludingPortionAttributableToNoncontrollingInterest"); ok {
+		return v, true
+	}
+	v, ok := r.number(col, "StockholdersEquity")
+	if !ok {
+		return 0, false
+	}
+	nci, _ := r.number(col, "MinorityInterest")
+	return v + nci, true
+}
+
+// Validates the accounting identities of every column of the report:
+//
+//	Assets = Liabilities and Equity = Liabilities + Equity
+//	Current + NonCurrent = Total, for assets and liabilities
+//	GrossProfit = Revenue - CostOfRevenue
+//	Operating + Investing + Financing + FX effect = change in cash
+//	beginning cash + change in cash = ending cash
+//
+// Checks whose inputs were not reported are skipped. Returns the identities
+// that do not hold within tolerance.
+func (r *FinancialStatement) Validate() []Discrepancy {
+	discrepancies := make([]Discrepancy, 0)
+	check := func(name string, col int, actual, expected float64) {
+		tolerance := math.Max(relTolerance*math.Max(math.Abs(actual), math.Abs(expected)), 1)
+		diff := actual - expected
+		if math.Abs(diff) > tolerance {
+			discrepancies = append(discrepancies, Discrepancy{
+				Check:      name,
+				Period:     r.Periods[col].Label,
+				Expected:   expected,
+				Actual:     actual,
+				Difference: diff,
+				Tolerance:  tolerance,
+			})
+		}
+	}
+
+	for col := range r.Periods {
+		assets, hasAssets := r.number(col, "Assets")
+		if hasAssets {
+			if le, ok := r.number(col, "LiabilitiesAndStockholdersEquity"); ok {
+				check("Assets = Liabilities and Equity", col, assets, le)
+			}
+			liabilities, okL := r.number(col, "Liabilities")
+			equity, okE := r.totalEquity(col)
+			if okL && okE {
+				check("Assets = Liabilities + Equity", col, assets, liabilities+equity)
+			}
+			current, okC := r.number(col, "AssetsCurrent")
+			noncurrent, okN := r.number(col, "AssetsNoncurrent")
+			if okC && okN {
+				check("Current + NonCurrent = Total Assets", col, assets, current+noncurrent)
+			}
+		}
+		if liabilities, ok := r.number(col, "Liabilities"); ok {
+			current, okC := r.number(col, "LiabilitiesCurrent")
+			noncurrent, okN := r.number(col, "LiabilitiesNoncurrent")
+			if okC && okN {
+				check("Current + NonCurrent = Total Liabilities", col, liabilities, current+noncurrent)
+			}
+		}
+
+		gp, okG := r.standardNumber("GrossProfit", col)
+		revenue, okR := r.standardNumber("TotalRevenue", col)
+		cogs, okC := r.standardNumber("COGS", col)
+		if okG && okR && okC {
+			check("GrossProfit = Revenue - CostOfRevenue", col, gp, revenue-cogs)
+		}
+
+		r.validateCash(col, check)
+	}
+	return discrepancies
+}
+
+// The cash concepts of the roll-forward, with the change and the effect of
+// exchange rates reported alongside each. Since ASU 2016-18 the statement
+// reconciles cash including restricted cash; earlier statements reconcile
+// cash and cash equivalents.
+var cashRollForwards = [][3]string{
+	{
+		"CashCashEquivalentsRestrictedCashAndRestrictedCashEquivalents",
+		"CashCashEquivalentsRestrictedCashAndRestrictedCashEquivalentsPeriodIncreaseDecreaseIncludingExchangeRateEffect",
+		"EffectOfExchangeRateOnCashCashEquivalentsRestrictedCashAndRestrictedCashEquivalents",
+	},
+	{
+		"CashAndCashEquivalentsAtCarryingValue",
+		"CashAndCashEquivalentsPeriodIncreaseDecrease",
+		"EffectOfExchangeRateOnCashAndCashEquivalents",
+	},
+}
+
+// Checks the cash flow statement of a column: the sum of the flows against
+// the reported change in cash, and the change against the balances at the
+// end of the column and of the column before, when that ends the day before
+// this one starts
+func (r *FinancialStatement) validateCash(col int, check func(string, int, float64, float64)) {
+	op, okO := r.number(col, "NetCashProvidedByUsedInOperatingActivities")
+	inv, okI := r.number(col, "NetCashProvidedByUsedInInvestingActivities")
+	fin, okF := r.number(col, "NetCashProvidedByUsedInFinancingActivities")
+	for _, rf := range cashRollForwards {
+		change, ok := r.conceptValue(rf[1], col)
+		if !ok {
+			continue
+		}
+		delta, err := strconv.ParseFloat(change.Value.String(), 64)
+		if err != nil {
+			continue
+		}
+		fx, _ := r.number(col, rf[2])
+		if okO && okI && okF {
+			check("Operating + Investing + Financing + FX = Change in Cash", col, delta, op+inv+fin+fx)
+		}
+		ending, okEnd := r.number(col, rf[0])
+		if col > 0 && okEnd && r.Periods[col-1].End == addDays(change.PeriodStart, -1) {
+			if beginning, ok := r.number(col-1, rf[0]); ok {
+				check("Beginning Cash + Change in Cash = Ending Cash", col, ending, beginning+delta)
+			}
+		}
+		return
+	}
+}
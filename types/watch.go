@@ -0,0 +1,282 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holds the options for the watch subcommand
+type WatchConfig struct {
+	Tickers  string        // comma separated watchlist
+	Forms    string        // comma separated form types or groups, empty watches all forms
+	Interval time.Duration // time between polls
+	State    string        // path of the file remembering seen accessions
+	Webhook  string        // URL that receives each event as a JSON POST
+	Exec     string        // shell command run for each event, event JSON on stdin
+	Backfill bool          // emit filings already on EDGAR when a company is first watched
+	Once     bool          // poll a single time and exit
+	Timeout  time.Duration // time allowed for each webhook or command hook
+}
+
+// A FilingEvent is emitted once for every new filing found by the watcher
+type FilingEvent struct {
+	Ticker     string `json:"ticker"`
+	CIK        string `json:"cik"`
+	Company    string `json:"company"`
+	Form       string `json:"form"`
+	Accession  string `json:"accession"`
+	FilingDate string `json:"filingDate"`
+	URL        string `json:"url"`
+}
+
+// Seen accession numbers, keyed by CIK. A CIK missing from the map has never
+// been polled. Events a hook failed to deliver are kept under the hook's
+// name, "webhook" or "exec", until a later poll delivers them.
+type watchState struct {
+	Companies map[string][]string      `json:"companies"`
+	Pending   map[string][]FilingEvent `json:"pending,omitempty"`
+}
+
+func loadWatchState(name string) (*watchState, error) {
+	st := &watchState{Companies: make(map[string][]string), Pending: make(map[string][]FilingEvent)}
+	b, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, st)
+	if err != nil {
+		return nil, err
+	}
+	if st.Companies == nil {
+		st.Companies = make(map[string][]string)
+	}
+	if st.Pending == nil {
+		st.Pending = make(map[string][]FilingEvent)
+	}
+	return st, nil
+}
+
+func (st *watchState) save(name string) error {
+	b, err := json.MarshalIndent(st, "", "	")
+	if err != nil {
+		return err
+	}
+	// Write then rename so an interrupted save never corrupts the state
+	tmp := name + ".tmp"
+	err = os.WriteFile(tmp, b, 0660)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (w *WatchConfig) HandleWatch() {
+	if w.Tickers == "" {
+		fmt.Println("Error: expected -tickers. Exiting...")
+		os.Exit(1)
+	}
+	c := checkConfig()
+	tickers := c.checkCompanyTickers()
+	watchlist := make(map[string]string) // ticker -> padded CIK
+	for _, t := range strings.Split(w.Tickers, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		cik, ok := tickers[t]
+		if !ok {
+			fmt.Printf("Error: ticker %s not found! Exiting program.\n", t)
+			os.Exit(1)
+		}
+		watchlist[t] = zeroPad(fmt.Sprint(cik))
+	}
+	forms, err := formSet(w.Forms)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = createDir(filepath.Dir(w.State))
+	if err != nil {
+		fmt.Printf("Error: could not create state directory! (%v)\n", err)
+		os.Exit(1)
+	}
+	st, err := loadWatchState(w.State)
+	if err != nil {
+		fmt.Printf("Error: could not read watch state %s! (%v)\n", w.State, err)
+		os.Exit(1)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	fmt.Fprintf(os.Stderr, "Watching %s every %v...\n", w.Tickers, w.Interval)
+	for {
+		w.poll(c, watchlist, forms, st)
+		err = st.save(w.State)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not save watch state! (%v)\n", err)
+		}
+		if w.Once {
+			return
+		}
+		select {
+		case <-time.After(w.Interval):
+		case <-interrupt:
+			fmt.Fprintln(os.Stderr, "Stopping watch.")
+			return
+		}
+	}
+}
+
+// Checks the submissions of every company on the watchlist and emits an event
+// for each filing that has not been seen before. Errors are reported and the
+// company is retried on the next poll. Events a hook failed to deliver are
+// first retried with that hook alone.
+func (w *WatchConfig) poll(c *ClientConfig, watchlist map[string]string, forms map[string]bool, st *watchState) {
+	w.retry(st)
+	names := make([]string, 0, len(watchlist))
+	for t := range watchlist {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+
+	for _, ticker := range names {
+		cik := watchlist[ticker]
+		body, err := c.makeSecRequest(assembleUrl(cik, companyFilings))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not poll %s! (%v)\n", ticker, err)
+			continue
+		}
+		var cf CompanyFilings
+		err = json.Unmarshal(body, &cf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not unmarshal submissions for %s! (%v)\n", ticker, err)
+			continue
+		}
+		seenList, known := st.Companies[cik]
+		seen := make(map[string]bool, len(seenList))
+		for _, a := range seenList {
+			seen[a] = true
+		}
+		recent := cf.Filings.Recent
+		// Submissions list the newest filings first; emit oldest first
+		for i := len(recent.AccessionNumber) - 1; i >= 0; i-- {
+			accession := recent.AccessionNumber[i]
+			if seen[accession] {
+				continue
+			}
+			wanted := len(forms) == 0 || forms[strings.ToUpper(recent.Form[i])]
+			if wanted && (known || w.Backfill) {
+				w.emit(st, FilingEvent{
+					Ticker:     ticker,
+					CIK:        cik,
+					Company:    cf.Name,
+					Form:       recent.Form[i],
+					Accession:  accession,
+					FilingDate: recent.FilingDate[i],
+					URL:        filingUrl(cf.Cik, accession, recent.PrimaryDocument[i]),
+				})
+			}
+			seen[accession] = true
+			seenList = append(seenList, accession)
+		}
+		st.Companies[cik] = seenList
+	}
+}
+
+// The hooks an event is passed to, in order
+var watchHooks = []string{"webhook", "exec"}
+
+// Writes the event to stdout as a JSON line, once, and passes it to the
+// webhook and command hooks, if configured. Delivery to the hooks is at least
+// once: an event a hook fails to deliver is kept in the state and retried
+// with that hook alone on the next poll, so a hook may see an event again if
+// it failed after acting on it.
+func (w *WatchConfig) emit(st *watchState, e FilingEvent) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not marshal event for %s! (%v)\n", e.Accession, err)
+		return
+	}
+	fmt.Println(string(b))
+	for _, hook := range watchHooks {
+		err = w.deliver(hook, e, b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v, retrying on the next poll\n", err)
+			st.Pending[hook] = append(st.Pending[hook], e)
+		}
+	}
+}
+
+// Redelivers the events each hook failed to deliver before, keeping those
+// that fail again
+func (w *WatchConfig) retry(st *watchState) {
+	for _, hook := range watchHooks {
+		pending := st.Pending[hook]
+		if len(pending) == 0 {
+			continue
+		}
+		failed := make([]FilingEvent, 0)
+		for _, e := range pending {
+			b, err := json.Marshal(e)
+			if err == nil {
+				err = w.deliver(hook, e, b)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v, retrying on the next poll\n", err)
+				failed = append(failed, e)
+			}
+		}
+		if len(failed) == 0 {
+			delete(st.Pending, hook)
+		} else {
+			st.Pending[hook] = failed
+		}
+	}
+}
+
+// Passes the event JSON b to one hook, if it is configured. The hook is given
+// w.Timeout to finish.
+func (w *WatchConfig) deliver(hook string, e FilingEvent, b []byte) error {
+	switch {
+	case hook == "webhook" && w.Webhook != "":
+		client := http.Client{Timeout: w.Timeout}
+		res, err := client.Post(w.Webhook, "application/json", bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("webhook failed for %s (%v)", e.Accession, err)
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %s for %s", res.Status, e.Accession)
+		}
+	case hook == "exec" && w.Exec != "":
+		ctx, cancel := context.WithTimeout(context.Background(), w.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", w.Exec)
+		cmd.Stdin = bytes.NewReader(b)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"EDGAR_TICKER="+e.Ticker,
+			"EDGAR_CIK="+e.CIK,
+			"EDGAR_FORM="+e.Form,
+			"EDGAR_ACCESSION="+e.Accession,
+			"EDGAR_FILING_DATE="+e.FilingDate,
+			"EDGAR_URL="+e.URL,
+		)
+		err := cmd.Run()
+		if err != nil {
+			return fmt.Errorf("command hook failed for %s (%v)", e.Accession, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,709 @@
+package types
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	labelRole     = "http://www.xbrl.org/2003/role/label"
+	terseRole     = "http://www.xbrl.org/2003/role/terseLabel"
+	instanceNS    = "http://www.xbrl.org/2003/instance"
+	dimensionNS   = "http://xbrl.org/2006/xbrldi"
+	xsiNS         = "http://www.w3.org/2001/XMLSchema-instance"
+	instanceDepth = 1 // facts, contexts and units are children of the root
+)
+
+// A Relationship is an arc of a presentation, calculation or definition
+// linkbase between two concepts, e.g. us-gaap:Revenues -> us-gaap:GrossProfit
+type Relationship struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Order          float64 `json:"order"`
+	Weight         float64 `json:"weight,omitempty"` // calculation arcs only
+	PreferredLabel string  `json:"preferredLabel,omitempty"`
+	Arcrole        string  `json:"arcrole"`
+}
+
+// A Linkbase holds the labels and relationships of a filing's taxonomy
+// extension. Relationships are keyed by extended link role, one per statement
+// or disclosure.
+type Linkbase struct {
+	Labels       map[string]map[string]string // concept -> label role -> label
+	Presentation map[string][]Relationship
+	Calculation  map[string][]Relationship
+	Definition   map[string][]Relationship
+}
+
+// An XBRLPackage is the instance document of a filing with its linkbases
+type XBRLPackage struct {
+	Facts []InlineFact
+	Linkbase
+}
+
+// The XML shape shared by all linkbases
+type xmlLinkbase struct {
+	Links []xmlLink `xml:",any"`
+}
+
+type xmlLink struct {
+	XMLName xml.Name
+	Role    string `xml:"http://www.w3.org/1999/xlink role,attr"`
+	Locs    []struct {
+		Href  string `xml:"http://www.w3.org/1999/xlink href,attr"`
+		Label string `xml:"http://www.w3.org/1999/xlink label,attr"`
+	} `xml:"loc"`
+	Labels []struct {
+		Label string `xml:"http://www.w3.org/1999/xlink label,attr"`
+		Role  string `xml:"http://www.w3.org/1999/xlink role,attr"`
+		Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+		Text  string `xml:",chardata"`
+	} `xml:"label"`
+	Arcs []xmlArc `xml:",any"`
+}
+
+type xmlArc struct {
+	XMLName        xml.Name
+	From           string `xml:"http://www.w3.org/1999/xlink from,attr"`
+	To             string `xml:"http://www.w3.org/1999/xlink to,attr"`
+	Arcrole        string `xml:"http://www.w3.org/1999/xlink arcrole,attr"`
+	Order          string `xml:"order,attr"`
+	Weight         string `xml:"weight,attr"`
+	PreferredLabel string `xml:"preferredLabel,attr"`
+}
+
+// Converts a locator href such as "aapl-20230930.xsd#us-gaap_Revenues" to
+// the concept QName us-gaap:Revenues
+func hrefConcept(href string) string {
+	frag := href[strings.Index(href, "#")+1:]
+	if i := strings.Index(frag, "_"); i >= 0 {
+		return frag[:i] + ":" + frag[i+1:]
+	}
+	return frag
+}
+
+func (lb *Linkbase) init() {
+	if lb.Labels == nil {
+		lb.Labels = make(map[string]map[string]string)
+		lb.Presentation = make(map[string][]Relationship)
+		lb.Calculation = make(map[string][]Relationship)
+		lb.Definition = make(map[string][]Relationship)
+	}
+}
+
+// Parses a presentation, calculation, definition or label linkbase and adds
+// its contents to lb
+func (lb *Linkbase) Parse(r io.Reader) error {
+	var doc xmlLinkbase
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return err
+	}
+	lb.init()
+	for _, link := range doc.Links {
+		locs := make(map[string]string) // xlink:label -> concept
+		for _, l := range link.Locs {
+			locs[l.Label] = hrefConcept(l.Href)
+		}
+		switch link.XMLName.Local {
+		case "labelLink":
+			labels := make(map[string][]int) // xlink:label -> label resources
+			for i, l := range link.Labels {
+				labels[l.Label] = append(labels[l.Label], i)
+			}
+			for _, arc := range link.Arcs {
+				concept, ok := locs[arc.From]
+				if !ok || arc.XMLName.Local != "labelArc" {
+					continue
+				}
+				if lb.Labels[concept] == nil {
+					lb.Labels[concept] = make(map[string]string)
+				}
+				for _, i := range labels[arc.To] {
+					l := link.Labels[i]
+					if l.Lang == "" || strings.HasPrefix(l.Lang, "en") {
+						lb.Labels[concept][l.Role] = strings.TrimSpace(l.Text)
+					}
+				}
+			}
+		case "presentationLink", "calculationLink", "definitionLink":
+			rels := make([]Relationship, 0, len(link.Arcs))
+			for _, arc := range link.Arcs {
+				from, okFrom := locs[arc.From]
+				to, okTo := locs[arc.To]
+				if !okFrom || !okTo {
+					continue
+				}
+				order, _ := strconv.ParseFloat(arc.Order, 64)
+				weight, _ := strconv.ParseFloat(arc.Weight, 64)
+				rels = append(rels, Relationship{From: from, To: to, Order: order, Weight: weight,
+					PreferredLabel: arc.PreferredLabel, Arcrole: arc.Arcrole})
+			}
+			sort.SliceStable(rels, func(i, j int) bool { return rels[i].Order < rels[j].Order })
+			switch link.XMLName.Local {
+			case "presentationLink":
+				lb.Presentation[link.Role] = append(lb.Presentation[link.Role], rels...)
+			case "calculationLink":
+				lb.Calculation[link.Role] = append(lb.Calculation[link.Role], rels...)
+			default:
+				lb.Definition[link.Role] = append(lb.Definition[link.Role], rels...)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the standard label of a concept, falling back to its terse label
+// and then to the concept name itself
+func (lb *Linkbase) Label(concept string) string {
+	labels := lb.Labels[concept]
+	for _, role := range []string{labelRole, terseRole} {
+		if l, ok := labels[role]; ok {
+			return l
+		}
+	}
+	return concept[strings.Index(concept, ":")+1:]
+}
+
+// Parses an XBRL instance document. Facts are returned in the same shape as
+// inline XBRL facts, dimensions included.
+func ParseInstance(r io.Reader) ([]InlineFact, error) {
+	dec := xml.NewDecoder(r)
+	prefixes := make(map[string]string) // namespace URI -> prefix
+	contexts := make(map[string]*ixContext)
+	units := make(map[string]string)
+	type rawFact struct {
+		name                                xml.Name
+		context, unit, decimals, value, nil string
+	}
+	raw := make([]rawFact, 0)
+
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == instanceDepth {
+				for _, a := range t.Attr {
+					if a.Name.Space == "xmlns" {
+						prefixes[a.Value] = a.Name.Local
+					}
+				}
+				continue
+			}
+			if depth != instanceDepth+1 {
+				continue
+			}
+			switch {
+			case t.Name.Space == instanceNS && t.Name.Local == "context":
+				var c xmlContext
+				err = dec.DecodeElement(&c, &t)
+				if err != nil {
+					return nil, err
+				}
+				contexts[c.ID] = c.context()
+				depth--
+			case t.Name.Space == instanceNS && t.Name.Local == "unit":
+				var u xmlUnit
+				err = dec.DecodeElement(&u, &t)
+				if err != nil {
+					return nil, err
+				}
+				units[u.ID] = u.name()
+				depth--
+			default:
+				f := rawFact{name: t.Name}
+				for _, a := range t.Attr {
+					switch {
+					case a.Name.Local == "contextRef":
+						f.context = a.Value
+					case a.Name.Local == "unitRef":
+						f.unit = a.Value
+					case a.Name.Local == "decimals":
+						f.decimals = a.Value
+					case a.Name.Space == xsiNS && a.Name.Local == "nil":
+						f.nil = a.Value
+					}
+				}
+				if f.context == "" {
+					dec.Skip()
+					depth--
+					continue
+				}
+				var text struct {
+					Value string `xml:",chardata"`
+				}
+				err = dec.DecodeElement(&text, &t)
+				if err != nil {
+					return nil, err
+				}
+				f.value = strings.TrimSpace(text.Value)
+				raw = append(raw, f)
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	facts := make([]InlineFact, 0, len(raw))
+	for _, rf := range raw {
+		if rf.nil == "true" {
+			continue
+		}
+		ctx, ok := contexts[rf.context]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown context %q", rf.name.Local, rf.context)
+		}
+		f := InlineFact{Taxonomy: prefixes[rf.name.Space], Concept: rf.name.Local, Decimals: rf.decimals}
+		f.PeriodStart, f.PeriodEnd = ctx.start, ctx.end
+		if len(ctx.dimensions) > 0 {
+			f.Dimensions = ctx.dimensions
+		}
+		if rf.unit != "" {
+			f.Unit = units[rf.unit]
+			f.Value = json.Number(rf.value)
+		} else {
+			f.Text = rf.value
+		}
+		facts = append(facts, f)
+	}
+	return facts, nil
+}
+
+type xmlContext struct {
+	ID     string `xml:"id,attr"`
+	Entity struct {
+		Segment struct {
+			Members []struct {
+				XMLName   xml.Name
+				Dimension string `xml:"dimension,attr"`
+				Value     string `xml:",innerxml"`
+			} `xml:",any"`
+		} `xml:"segment"`
+	} `xml:"entity"`
+	Period struct {
+		Start   string `xml:"startDate"`
+		End     string `xml:"endDate"`
+		Instant string `xml:"instant"`
+	} `xml:"period"`
+}
+
+func (c *xmlContext) context() *ixContext {
+	ctx := &ixContext{start: c.Period.Start, end: c.Period.End, dimensions: make(map[string]string)}
+	if c.Period.Instant != "" {
+		ctx.end = c.Period.Instant
+	}
+	for _, m := range c.Entity.Segment.Members {
+		if m.XMLName.Space != dimensionNS {
+			continue
+		}
+		v := strings.TrimSpace(m.Value)
+		if m.XMLName.Local == "typedMember" {
+			// Typed members wrap their value in a domain element
+			v = strings.TrimSpace(xmlText(v))
+		}
+		ctx.dimensions[m.Dimension] = v
+	}
+	return ctx
+}
+
+type xmlUnit struct {
+	ID       string   `xml:"id,attr"`
+	Measures []string `xml:"measure"`
+	Divide   struct {
+		Numerator   []string `xml:"unitNumerator>measure"`
+		Denominator []string `xml:"unitDenominator>measure"`
+	} `xml:"divide"`
+}
+
+// Returns the unit as companyfacts names it: "USD", "shares" or "USD/shares"
+func (u *xmlUnit) name() string {
+	local := func(ms []string) string {
+		out := make([]string, len(ms))
+		for i, m := range ms {
+			m = strings.TrimSpace(m)
+			out[i] = m[strings.Index(m, ":")+1:]
+		}
+		return strings.Join(out, "*")
+	}
+	if len(u.Divide.Numerator) > 0 {
+		return local(u.Divide.Numerator) + "/" + local(u.Divide.Denominator)
+	}
+	return local(u.Measures)
+}
+
+// Strips the tags from an XML fragment
+func xmlText(fragment string) string {
+	var b strings.Builder
+	dec := xml.NewDecoder(strings.NewReader("<x>" + fragment + "</x>"))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			b.Write(cd)
+		}
+	}
+	return b.String()
+}
+
+// Returns the facts reported for a member of an axis, e.g. the facts of the
+// us-gaap:StatementBusinessSegmentsAxis. An empty member matches every member
+// of the axis. Facts with further dimensions are left out.
+func (p *XBRLPackage) FactsByMember(axis, member string) []InlineFact {
+	facts := make([]InlineFact, 0)
+	for _, f := range p.Facts {
+		m, ok := f.Dimensions[axis]
+		if !ok || len(f.Dimensions) != 1 || (member != "" && m != member) {
+			continue
+		}
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+// Returns the axes used by the facts of the package with the number of facts
+// reported on each
+func (p *XBRLPackage) Axes() map[string]int {
+	axes := make(map[string]int)
+	for _, f := range p.Facts {
+		for axis := range f.Dimensions {
+			axes[axis]++
+		}
+	}
+	return axes
+}
+
+// Renders the numeric facts on an axis as a table: one row per concept, in
+// presentation order where known, and one column per member. Only facts for
+// the given period end are included; an empty end selects the latest one.
+func (p *XBRLPackage) SegmentTable(axis, end string) *FinancialTable {
+	facts := p.FactsByMember(axis, "")
+	if end == "" {
+		for _, f := range facts {
+			if f.Value != "" && f.PeriodEnd > end {
+				end = f.PeriodEnd
+			}
+		}
+	}
+	members := make([]string, 0)
+	concepts := make([]string, 0)
+	values := make(map[string]map[string]InlineFact) // concept -> member -> fact
+	for _, f := range facts {
+		if f.Value == "" || f.PeriodEnd != end {
+			continue
+		}
+		concept := f.Taxonomy + ":" + f.Concept
+		member := f.Dimensions[axis]
+		if values[concept] == nil {
+			values[concept] = make(map[string]InlineFact)
+			concepts = append(concepts, concept)
+		}
+		if _, ok := values[concept][member]; !ok {
+			found := false
+			for _, m := range members {
+				found = found || m == member
+			}
+			if !found {
+				members = append(members, member)
+			}
+		}
+		// Prefer the longest duration when a concept is reported for
+		// several periods ending on the same date
+		if prev, ok := values[concept][member]; !ok || f.PeriodStart < prev.PeriodStart {
+			values[concept][member] = f
+		}
+	}
+	order := p.presentationOrder()
+	sort.SliceStable(concepts, func(i, j int) bool {
+		oi, iok := order[concepts[i]]
+		oj, jok := order[concepts[j]]
+		if iok != jok {
+			return iok
+		}
+		return oi < oj
+	})
+
+	t := &FinancialTable{Caption: p.Label(axis) + " (" + end + ")", Scale: 1}
+	for _, m := range members {
+		t.Columns = append(t.Columns, p.Label(m))
+	}
+	for _, concept := range concepts {
+		row := TableRow{Label: p.Label(concept), Concept: concept, Cells: make([]TableCell, len(members))}
+		for i, m := range members {
+			f, ok := values[concept][m]
+			if !ok {
+				continue
+			}
+			cell := TableCell{Text: f.Value.String(), Unit: f.Unit}
+			if v, err := f.Value.Float64(); err == nil {
+				cell.Value = &v
+			}
+			row.Cells[i] = cell
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// Returns the position of each concept in a depth-first walk of the
+// presentation linkbase
+func (p *XBRLPackage) presentationOrder() map[string]int {
+	order := make(map[string]int)
+	roles := make([]string, 0, len(p.Presentation))
+	for role := range p.Presentation {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		rels := p.Presentation[role]
+		children := make(map[string][]string)
+		isChild := make(map[string]bool)
+		for _, r := range rels {
+			children[r.From] = append(children[r.From], r.To)
+			isChild[r.To] = true
+		}
+		var visit func(c string)
+		visit = func(c string) {
+			if _, ok := order[c]; !ok {
+				order[c] = len(order)
+			}
+			for _, child := range children[c] {
+				visit(child)
+			}
+		}
+		for _, r := range rels {
+			if !isChild[r.From] {
+				visit(r.From)
+			}
+		}
+	}
+	return order
+}
+
+// Loads the instance and linkbases of a filing package from a directory.
+// The instance is the *_htm.xml extracted from an inline XBRL filing or,
+// for older filings, the .xml named after the taxonomy schema.
+func LoadXBRLPackage(dir string) (*XBRLPackage, error) {
+	instance, linkbases, err := packageFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, instance))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := &XBRLPackage{}
+	p.Linkbase.init()
+	p.Facts, err = ParseInstance(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", instance, err)
+	}
+	for _, name := range linkbases {
+		lf, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		err = p.Linkbase.Parse(lf)
+		lf.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return p, nil
+}
+
+// Picks the instance document and linkbases out of a list of file names
+func selectPackageFiles(names []string) (string, []string, error) {
+	instance, schema := "", ""
+	for _, n := range names {
+		switch {
+		case strings.HasSuffix(n, "_htm.xml"):
+			instance = n
+		case strings.HasSuffix(n, ".xsd"):
+			schema = strings.TrimSuffix(n, ".xsd")
+		}
+	}
+	if instance == "" && schema != "" {
+		for _, n := range names {
+			if n == schema+".xml" {
+				instance = n
+			}
+		}
+	}
+	if instance == "" {
+		return "", nil, fmt.Errorf("no XBRL instance document found")
+	}
+	linkbases := make([]string, 0)
+	for _, n := range names {
+		for _, suffix := range []string{"_pre.xml", "_cal.xml", "_def.xml", "_lab.xml"} {
+			if strings.HasSuffix(n, suffix) {
+				linkbases = append(linkbases, n)
+			}
+		}
+	}
+	return instance, linkbases, nil
+}
+
+func packageFiles(dir string) (string, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return selectPackageFiles(names)
+}
+
+// Holds the options for the xbrl subcommand
+type XBRLConfig struct {
+	Ticker    string
+	CIK       string
+	Accession string // filing to download, e.g. 0000320193-23-000106
+	Dir       string // local filing package, used instead of downloading
+	Axis      string // e.g. us-gaap:StatementBusinessSegmentsAxis
+	Member    string // optional, lists the facts of a single member
+	End       string // period end of the segment table, latest if empty
+	Format    string // json or csv
+}
+
+// The JSON listing of a filing folder in the EDGAR archives
+type filingIndex struct {
+	Directory struct {
+		Item []struct {
+			Name string `json:"name"`
+		} `json:"item"`
+	} `json:"directory"`
+}
+
+// Downloads the instance and linkbases of the configured filing into
+// app/<ticker>/<accession>/ and returns the directory
+func (x *XBRLConfig) downloadPackage(c *ClientConfig) (string, error) {
+	if x.CIK == "" {
+		cik, ok := c.checkCompanyTickers()[strings.ToUpper(x.Ticker)]
+		if !ok {
+			return "", fmt.Errorf("ticker %s not found", x.Ticker)
+		}
+		x.CIK = strconv.Itoa(cik)
+	}
+	cik, err := cikNumber(x.CIK)
+	if err != nil {
+		return "", err
+	}
+	folder := filingUrl(strconv.Itoa(cik), x.Accession, "")
+	body, err := c.makeSecRequest(folder + "index.json")
+	if err != nil {
+		return "", err
+	}
+	var idx filingIndex
+	err = json.Unmarshal(body, &idx)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(idx.Directory.Item))
+	for _, item := range idx.Directory.Item {
+		names = append(names, item.Name)
+	}
+	instance, linkbases, err := selectPackageFiles(names)
+	if err != nil {
+		return "", err
+	}
+	urls := []string{folder + instance}
+	for _, l := range linkbases {
+		urls = append(urls, folder+l)
+	}
+	dir := "app/" + x.Ticker + "/" + x.Accession + "/"
+	return dir, downloadFiles(urls, dir, c)
+}
+
+func (x *XBRLConfig) HandleXBRL() {
+	dir := x.Dir
+	if dir == "" {
+		if x.Accession == "" || (x.Ticker == "" && x.CIK == "") {
+			fmt.Println("Error: expected -dir, or -accession with -ticker or -cik. Exiting...")
+			os.Exit(1)
+		}
+		var err error
+		dir, err = x.downloadPackage(checkConfig())
+		if err != nil {
+			fmt.Printf("Error: could not download XBRL package! (%v)\n", err)
+			os.Exit(1)
+		}
+	}
+	p, err := LoadXBRLPackage(dir)
+	if err != nil {
+		fmt.Printf("Error: could not load XBRL package! (%v)\n", err)
+		os.Exit(1)
+	}
+
+	if x.Axis == "" {
+		axes := p.Axes()
+		names := make([]string, 0, len(axes))
+		for a := range axes {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		fmt.Printf("%d facts in %s. Axes:\n", len(p.Facts), dir)
+		for _, a := range names {
+			fmt.Printf("%s\t%d facts\t%s\n", a, axes[a], p.Label(a))
+		}
+		return
+	}
+
+	name := strings.ReplaceAll(x.Axis, ":", "_")
+	var out string
+	if x.Member != "" {
+		b, err := json.MarshalIndent(p.FactsByMember(x.Axis, x.Member), "", "	")
+		if err != nil {
+			fmt.Printf("Error: could not marshal facts! (%v)\n", err)
+			os.Exit(1)
+		}
+		out = filepath.Join(dir, name+"_"+strings.ReplaceAll(x.Member, ":", "_")+".json")
+		err = os.WriteFile(out, b, 0666)
+		if err != nil {
+			fmt.Printf("Error: could not write file! (%v)\n", err)
+			os.Exit(1)
+		}
+	} else {
+		t := p.SegmentTable(x.Axis, x.End)
+		if len(t.Rows) == 0 {
+			fmt.Printf("Error: no facts found for %s!\n", x.Axis)
+			os.Exit(1)
+		}
+		if x.Format == "csv" {
+			out = filepath.Join(dir, name+".csv")
+			err = writeTableCSV(out, t)
+		} else {
+			var b []byte
+			b, err = json.MarshalIndent(t, "", "	")
+			out = filepath.Join(dir, name+".json")
+			if err == nil {
+				err = os.WriteFile(out, b, 0666)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error: could not write file! (%v)\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Your report is located at %s\n", out)
+}
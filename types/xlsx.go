@@ -0,0 +1,230 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Number formats of -scale for USD and share amounts. Excel divides a
+// number by a thousand for each trailing comma of its format, so cells
+// keep the reported values while displaying them scaled.
+var scaleFormats = map[string]string{
+	"units":     "#,##0;(#,##0)",
+	"thousands": "#,##0,;(#,##0,)",
+	"millions":  "#,##0.0,,;(#,##0.0,,)",
+}
+
+// The statement sheets of the workbook: the standardized lines of the
+// statement, then the as-reported line items of its report sections
+var workbookSheets = []struct{ name, statement, sections string }{
+	{"Income Statement", IncomeStatement, "Income Statement/"},
+	{"Balance Sheet", BalanceSheet, "Balance Sheet/"},
+	{"Cash Flow", CashFlowStatement, "Cash Flow Statement/"},
+}
+
+// Rows above the values: period labels and period ends
+const headerRows = 2
+
+// A workbook being written. The first error of any cell operation is kept
+// and reported by save, so writing reads top to bottom.
+type workbook struct {
+	f      *excelize.File
+	r      *FinancialStatement
+	styles map[string]int // style IDs keyed by number format, "bold" for headings
+	err    error
+}
+
+func (wb *workbook) check(err error) {
+	if wb.err == nil {
+		wb.err = err
+	}
+}
+
+// Returns the name of the cell at a 1-based column and row
+func cellName(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
+
+// Returns the ID of a style with the given number format, or a bold font
+// for "bold"
+func (wb *workbook) style(format string) int {
+	if id, ok := wb.styles[format]; ok {
+		return id
+	}
+	s := &excelize.Style{CustomNumFmt: &format}
+	if format == "bold" {
+		s = &excelize.Style{Font: &excelize.Font{Bold: true}}
+	}
+	id, err := wb.f.NewStyle(s)
+	wb.check(err)
+	wb.styles[format] = id
+	return id
+}
+
+// Starts a sheet with the period header rows frozen above the values and
+// the line names frozen to their left
+func (wb *workbook) sheet(name, title string) {
+	if wb.f.SheetCount == 1 && wb.f.GetSheetName(0) == "Sheet1" {
+		wb.check(wb.f.SetSheetName("Sheet1", name))
+	} else {
+		_, err := wb.f.NewSheet(name)
+		wb.check(err)
+	}
+	wb.check(wb.f.SetCellValue(name, "A1", title))
+	for i, p := range wb.r.Periods {
+		wb.check(wb.f.SetCellValue(name, cellName(i+2, 1), p.Label))
+		wb.check(wb.f.SetCellValue(name, cellName(i+2, 2), p.End))
+	}
+	wb.check(wb.f.SetCellStyle(name, "A1", cellName(len(wb.r.Periods)+1, headerRows), wb.style("bold")))
+	wb.check(wb.f.SetColWidth(name, "A", "A", 48))
+	wb.check(wb.f.SetPanes(name, &excelize.Panes{
+		Freeze:      true,
+		XSplit:      1,
+		YSplit:      headerRows,
+		TopLeftCell: cellName(2, headerRows+1),
+		ActivePane:  "bottomRight",
+	}))
+}
+
+// Writes a heading row
+func (wb *workbook) heading(sheet string, row int, text string) {
+	wb.check(wb.f.SetCellValue(sheet, cellName(1, row), text))
+	wb.check(wb.f.SetCellStyle(sheet, cellName(1, row), cellName(1, row), wb.style("bold")))
+}
+
+// Writes a row of values in the number format, noting the concept each
+// value was read from, and how derived or restated values came about, in
+// the cell's comment
+func (wb *workbook) values(sheet string, row int, name, concept, format string, data []*ReportValue) {
+	wb.check(wb.f.SetCellValue(sheet, cellName(1, row), name))
+	for i, v := range data {
+		if v == nil {
+			continue
+		}
+		n, err := v.Value.Float64()
+		if err != nil {
+			continue
+		}
+		cell := cellName(i+2, row)
+		wb.check(wb.f.SetCellValue(sheet, cell, n))
+		wb.check(wb.f.SetCellStyle(sheet, cell, cell, wb.style(format)))
+		source := concept
+		if v.Concept != "" {
+			source = v.Concept
+		}
+		note := []string{"us-gaap:" + source}
+		if v.Derived {
+			note = append(note, "Derived: "+v.Basis)
+		}
+		if v.Restated {
+			note = append(note, "Restated, first reported as "+v.Original.String())
+		}
+		if v.Accession != "" {
+			note = append(note, v.Form+" "+v.Accession)
+		}
+		wb.check(wb.f.AddComment(sheet, excelize.Comment{Author: "edgar", Cell: cell, Text: strings.Join(note, "\n")}))
+	}
+}
+
+// Returns the number format of values in the unit
+func unitFormat(unit, scale string) string {
+	if unit == "USD/shares" {
+		return "0.00;(0.00)"
+	}
+	return scaleFormats[scale]
+}
+
+// Writes a statement sheet: its standardized lines, then the line items of
+// its report sections as reported
+func (wb *workbook) statement(name, statement, sections, scale string) {
+	wb.sheet(name, fmt.Sprintf("%s (USD %s)", name, scale))
+	row := headerRows + 1
+	for _, sl := range wb.r.Standardized {
+		if sl.Statement != statement {
+			continue
+		}
+		wb.values(name, row, sl.Name, "", unitFormat(sl.Unit, scale), sl.Data)
+		row++
+	}
+	for _, s := range wb.r.Sections() {
+		if !strings.HasPrefix(s.Name, sections) || len(*s.Items) == 0 {
+			continue
+		}
+		row++
+		wb.heading(name, row, strings.TrimPrefix(s.Name, sections))
+		row++
+		for _, li := range *s.Items {
+			wb.values(name, row, li.Tag, li.Concept, unitFormat(li.Unit, scale), li.Data)
+			row++
+		}
+	}
+}
+
+// Writes the ratios sheet, grouped by category. Margins and returns are
+// percentages. The formula of each ratio, and why a value is missing or
+// was assumed, are in the cell comments.
+func (wb *workbook) ratios() {
+	const name = "Ratios"
+	wb.sheet(name, name)
+	row, category := headerRows+1, ""
+	for _, ratio := range wb.r.Ratios {
+		if ratio.Category != category {
+			category = ratio.Category
+			row++
+			wb.heading(name, row, category)
+			row++
+		}
+		format := "0.00"
+		if category == "margins" || category == "returns" {
+			format = "0.0%"
+		}
+		wb.check(wb.f.SetCellValue(name, cellName(1, row), ratio.Name))
+		wb.check(wb.f.AddComment(name, excelize.Comment{Author: "edgar", Cell: cellName(1, row), Text: ratio.Formula}))
+		for i, v := range ratio.Values {
+			cell := cellName(i+2, row)
+			note := v.Note
+			if len(v.Missing) > 0 {
+				note = "Missing " + strings.Join(v.Missing, ", ")
+			}
+			if note != "" {
+				wb.check(wb.f.AddComment(name, excelize.Comment{Author: "edgar", Cell: cell, Text: note}))
+			}
+			if v.Value == nil {
+				continue
+			}
+			wb.check(wb.f.SetCellValue(name, cell, *v.Value))
+			wb.check(wb.f.SetCellStyle(name, cell, cell, wb.style(format)))
+		}
+		row++
+	}
+}
+
+// Writes the report as an Excel workbook with a sheet per statement and
+// one for the ratios to the app directory. USD and share amounts are
+// displayed in g.Scale.
+func (g *GetConfig) downloadXLSX(r *FinancialStatement) error {
+	err := createDir("app/" + g.Ticker + "/")
+	if err != nil {
+		fmt.Printf("Error: could not create 'app' directory! (%v)\n", err)
+		return err
+	}
+	wb := &workbook{f: excelize.NewFile(), r: r, styles: make(map[string]int)}
+	defer wb.f.Close()
+	for _, s := range workbookSheets {
+		wb.statement(s.name, s.statement, s.sections, g.Scale)
+	}
+	wb.ratios()
+	if wb.err != nil {
+		fmt.Printf("Error: could not assemble workbook! (%v)\n", wb.err)
+		return wb.err
+	}
+	err = wb.f.SaveAs("./app/" + g.Ticker + "/" + g.RawFile + ".xlsx")
+	if err != nil {
+		fmt.Printf("Error: could not write file to app dir! (%v)\n", err)
+		return err
+	}
+	return nil
+}